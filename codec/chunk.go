@@ -0,0 +1,291 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+var (
+	// MaxMessageSize is the default max size of message reassembled from chunked frames.
+	MaxMessageSize = 256 * 1024 * 1024
+	// MaxPendingSize is the default max total size of incomplete messages of all streams in reassembler.
+	MaxPendingSize = 512 * 1024 * 1024
+	// MaxPendingStreams is the default max number of streams with incomplete message in reassembler.
+	MaxPendingStreams = 1024
+	// ChunkStreamTimeout is the default time after which the stream receiving no chunk is abandoned.
+	ChunkStreamTimeout = 30 * time.Second
+)
+
+var (
+	ErrMessageTooLarge = errors.New("length of chunked message is larger than max message size")
+	ErrChunkSequence   = errors.New("sequence of chunked frame is out of order")
+)
+
+// ConstructChunks splits body into chunked frames, each frame carries at most chunkSize bytes
+// of body, and header is only carried in the first frame. chunkSize <= 0 means that the frames
+// are as large as MaxFrameSize. A body that fits in one frame is also constructed as a chunked
+// frame, with both FrameFlagChunked and FrameFlagEndOfMessage set.
+func (h *FrameHead) ConstructChunks(streamID uint32, header, body []byte, chunkSize int) ([][]byte, error) {
+	var frames [][]byte
+	err := h.splitChunks(streamID, header, body, chunkSize, func(header, chunk []byte) error {
+		frame, err := h.Construct(header, chunk)
+		if err != nil {
+			return err
+		}
+		frames = append(frames, frame)
+		return nil
+	})
+	return frames, err
+}
+
+// WriteChunks is same as ConstructChunks, but writes the chunked frames to w by WriteFrame.
+func (h *FrameHead) WriteChunks(w io.Writer, streamID uint32, header, body []byte, chunkSize int) (int64, error) {
+	var total int64
+	err := h.splitChunks(streamID, header, body, chunkSize, func(header, chunk []byte) error {
+		n, err := h.WriteFrame(w, header, chunk)
+		total += n
+		return err
+	})
+	return total, err
+}
+
+// splitChunks calls handle with the head set for each chunk, the flags, stream id and sequence
+// of the head are restored after splitting.
+func (h *FrameHead) splitChunks(streamID uint32, header, body []byte, chunkSize int,
+	handle func(header, chunk []byte) error) error {
+	reserved, oldStreamID, sequence := h.Reserved, h.StreamID, h.Sequence
+	defer func() {
+		h.Reserved, h.StreamID, h.Sequence = reserved, oldStreamID, sequence
+	}()
+
+	h.Reserved = (h.Reserved | FrameFlagChunked) &^ FrameFlagEndOfMessage
+	h.StreamID = streamID
+	h.Sequence = 0
+
+	maxChunkSize := MaxFrameSize - h.HeadLen() - len(header)
+	if chunkSize <= 0 || chunkSize > maxChunkSize {
+		chunkSize = maxChunkSize
+	}
+
+	if chunkSize <= 0 {
+		return ErrFrameTooLarge
+	}
+
+	for {
+		chunk := body
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		} else {
+			h.Reserved |= FrameFlagEndOfMessage
+		}
+
+		if err := handle(header, chunk); err != nil {
+			return err
+		}
+
+		if h.Reserved&FrameFlagEndOfMessage != 0 {
+			return nil
+		}
+
+		body = body[len(chunk):]
+		header = nil
+		h.Sequence++
+	}
+}
+
+// Reassembler reassembles chunked frames of multiple streams into complete messages. Besides the size
+// of each message, the total size and number of incomplete messages are limited, and the stream
+// receiving no chunk for the stream timeout is evicted, so that a peer can not hold memory forever
+// by opening streams without finishing them. It is not goroutine-safe, each connection
+// should have its own reassembler, which is read by one goroutine.
+type Reassembler struct {
+	maxMessageSize int64
+	maxPendingSize int64
+	maxStreams     int
+	streamTimeout  time.Duration
+	pendingSize    int64 // total size of incomplete messages.
+	streams        map[uint32]*chunkedMessage
+}
+
+type chunkedMessage struct {
+	head       FrameHead
+	next       uint32    // next sequence
+	buf        []byte    // frame head + header + body received
+	lastActive time.Time // time of the last chunk received
+}
+
+// NewReassembler create a reassembler, maxMessageSize is the max size of message,
+// which is MaxMessageSize by default. The total size and number of incomplete messages
+// are limited by MaxPendingSize and MaxPendingStreams, streams are abandoned after
+// ChunkStreamTimeout, which can be changed by WithMaxPending and WithStreamTimeout.
+func NewReassembler(maxMessageSize int) *Reassembler {
+	if maxMessageSize <= 0 {
+		maxMessageSize = MaxMessageSize
+	}
+
+	size := int64(maxMessageSize)
+	if size > math.MaxUint32 {
+		size = math.MaxUint32
+	}
+
+	return &Reassembler{
+		maxMessageSize: size,
+		maxPendingSize: int64(MaxPendingSize),
+		maxStreams:     MaxPendingStreams,
+		streamTimeout:  ChunkStreamTimeout,
+		streams:        map[uint32]*chunkedMessage{},
+	}
+}
+
+// WithMaxPending sets the max total size and max number of incomplete messages of all streams.
+func (r *Reassembler) WithMaxPending(maxSize, maxStreams int) {
+	r.maxPendingSize = int64(maxSize)
+	r.maxStreams = maxStreams
+}
+
+// WithStreamTimeout sets the time after which the stream receiving no chunk is abandoned.
+func (r *Reassembler) WithStreamTimeout(timeout time.Duration) {
+	r.streamTimeout = timeout
+}
+
+// Add adds a frame read by FrameReader to reassembler, and releases it if it is a chunked frame.
+// It returns the complete message as an unchunked normal frame when the last chunk arrives,
+// or nil when more chunks are required. Frames that are not chunked are returned directly.
+// ErrMessageTooLarge is returned if the message or the incomplete messages of all streams
+// exceed the limits, and the chunks received of the stream are discarded.
+func (r *Reassembler) Add(f *Frame) (*Frame, error) {
+	if f.FrameType != FrameTypeNormal || f.Head.Reserved&FrameFlagChunked == 0 {
+		return f, nil
+	}
+
+	defer f.Release()
+
+	now := time.Now()
+	streamID := f.Head.StreamID
+	msg, ok := r.streams[streamID]
+	if !ok {
+		if f.Head.Sequence != 0 {
+			return nil, ErrChunkSequence
+		}
+
+		r.evict(now)
+		if len(r.streams) >= r.maxStreams {
+			return nil, ErrMessageTooLarge
+		}
+
+		msg = &chunkedMessage{head: f.Head, lastActive: now}
+		msg.buf = make([]byte, FrameHeadLen, FrameHeadLen+len(f.Header)+len(f.Body))
+		msg.buf = append(msg.buf, f.Header...)
+		r.streams[streamID] = msg
+		r.pendingSize += int64(len(msg.buf))
+	} else if f.Head.Sequence != msg.next {
+		r.Discard(streamID)
+		return nil, ErrChunkSequence
+	}
+
+	msg.lastActive = now // so that the stream is not evicted below.
+
+	if int64(len(msg.buf))+int64(len(f.Body)) > r.maxMessageSize {
+		r.Discard(streamID)
+		return nil, ErrMessageTooLarge
+	}
+
+	if r.pendingSize+int64(len(f.Body)) > r.maxPendingSize {
+		r.evict(now)
+		if r.pendingSize+int64(len(f.Body)) > r.maxPendingSize {
+			r.Discard(streamID)
+			return nil, ErrMessageTooLarge
+		}
+	}
+
+	msg.buf = append(msg.buf, f.Body...)
+	msg.next++
+	r.pendingSize += int64(len(f.Body))
+
+	if f.Head.Reserved&FrameFlagEndOfMessage == 0 {
+		return nil, nil
+	}
+
+	r.Discard(streamID)
+	return msg.frame(), nil
+}
+
+// Discard discards the chunks received of the stream.
+func (r *Reassembler) Discard(streamID uint32) {
+	if msg, ok := r.streams[streamID]; ok {
+		r.pendingSize -= int64(len(msg.buf))
+		delete(r.streams, streamID)
+	}
+}
+
+// evict discards the streams receiving no chunk for the stream timeout.
+func (r *Reassembler) evict(now time.Time) {
+	if r.streamTimeout <= 0 {
+		return
+	}
+
+	for streamID, msg := range r.streams {
+		if now.Sub(msg.lastActive) > r.streamTimeout {
+			r.Discard(streamID)
+		}
+	}
+}
+
+// frame returns the complete message as an unchunked normal frame.
+func (m *chunkedMessage) frame() *Frame {
+	f := framePool.Get().(*Frame)
+	f.FrameType = FrameTypeNormal
+	f.Head = m.head
+	f.Head.Reserved &^= FrameFlagChecksum | FrameFlagChunked | FrameFlagEndOfMessage
+	f.Head.TotalLen = uint32(len(m.buf))
+	f.Head.Checksum, f.Head.StreamID, f.Head.Sequence = 0, 0, 0
+
+	f.buf = m.buf
+	f.Head.encode(f.buf)
+
+	headerEnd := FrameHeadLen + int(f.Head.HeaderLen)
+	f.Header = f.buf[FrameHeadLen:headerEnd]
+	f.Body = f.buf[headerEnd:]
+	return f
+}
+
+// WithMaxMessageSize sets the max size of message reassembled by ReadMessage.
+func (fr *FrameReader) WithMaxMessageSize(n int) {
+	fr.reassembler = NewReassembler(n)
+}
+
+// ReadMessage reads frames until a complete message is read, chunked frames are reassembled
+// into an unchunked normal frame. The returned frame should be released by Release after it
+// is no longer used.
+func (fr *FrameReader) ReadMessage() (*Frame, error) {
+	if fr.reassembler == nil {
+		fr.reassembler = NewReassembler(MaxMessageSize)
+	}
+
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		msg, err := fr.reassembler.Add(f)
+		if err != nil || msg != nil {
+			return msg, err
+		}
+	}
+}
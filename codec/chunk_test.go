@@ -0,0 +1,257 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// chunkFrames constructs the chunked frames of stream, and reads them back by FrameReader.
+func chunkFrames(t *testing.T, streamID uint32, header, body []byte, chunkSize int) []*Frame {
+	h := NewFrameHead()
+	h.EnableChecksum()
+
+	buf, err := h.ConstructChunks(streamID, header, body, chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Reserved != FrameFlagChecksum || h.StreamID != 0 || h.Sequence != 0 {
+		t.Fatalf("frame head is not restored after splitting: %+v", h)
+	}
+
+	fr := NewFrameReader(bytes.NewReader(bytes.Join(buf, nil)))
+	frames := make([]*Frame, 0, len(buf))
+	for range buf {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+func TestReassembler(t *testing.T) {
+	header, body := []byte("header"), bytes.Repeat([]byte("0123456789"), 10)
+
+	r := NewReassembler(0)
+	frames1 := chunkFrames(t, 1, header, body, 15)
+	frames2 := chunkFrames(t, 2, nil, body[:20], 15)
+
+	if len(frames1) != 7 || len(frames2) != 2 {
+		t.Fatalf("%d and %d chunks constructed", len(frames1), len(frames2))
+	}
+
+	// chunks of different streams are interleaved.
+	var msgs []*Frame
+	for i, f := range frames1 {
+		fs := []*Frame{f}
+		if i < len(frames2) {
+			fs = append(fs, frames2[i])
+		}
+
+		for _, f := range fs {
+			msg, err := r.Add(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg != nil {
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+
+	if len(msgs) != 2 || len(r.streams) != 0 || r.pendingSize != 0 {
+		t.Fatalf("%d messages reassembled, %d streams pending", len(msgs), len(r.streams))
+	}
+
+	for i, want := range [][]byte{body[:20], body} {
+		msg := msgs[i]
+		if msg.Head.Reserved != 0 || msg.Head.StreamID != 0 || msg.Head.Sequence != 0 || msg.Head.Checksum != 0 {
+			t.Fatalf("reassembled message is not an unchunked frame: %+v", msg.Head)
+		}
+
+		if !bytes.Equal(msg.Body, want) || int(msg.Head.TotalLen) != len(msg.Bytes()) {
+			t.Fatalf("body of message %d mismatch", i)
+		}
+
+		// the reassembled message is a valid normal frame.
+		f, err := NewFrameReader(bytes.NewReader(msg.Bytes())).ReadFrame()
+		if err != nil || !bytes.Equal(f.Body, want) {
+			t.Fatalf("reassembled message can not be read back: %v", err)
+		}
+	}
+
+	if !bytes.Equal(msgs[1].Header, header) || len(msgs[0].Header) != 0 {
+		t.Fatal("header of message mismatch")
+	}
+}
+
+func TestReassemblerErrors(t *testing.T) {
+	header, body := []byte("header"), bytes.Repeat([]byte("0123456789"), 10)
+
+	tests := []struct {
+		name  string
+		setup func(r *Reassembler)
+		add   func(t *testing.T) []*Frame // the last frame added fails
+		err   error
+	}{
+		{"first chunk missing", nil, func(t *testing.T) []*Frame {
+			return chunkFrames(t, 1, header, body, 15)[1:2]
+		}, ErrChunkSequence},
+		{"chunk out of order", nil, func(t *testing.T) []*Frame {
+			frames := chunkFrames(t, 1, header, body, 15)
+			return []*Frame{frames[0], frames[2]}
+		}, ErrChunkSequence},
+		{"chunk duplicated", nil, func(t *testing.T) []*Frame {
+			frames := chunkFrames(t, 1, header, body, 15)
+			return []*Frame{frames[0], frames[1], chunkFrames(t, 1, header, body, 15)[1]}
+		}, ErrChunkSequence},
+		{"message too large", func(r *Reassembler) {
+			r.maxMessageSize = 50
+		}, func(t *testing.T) []*Frame {
+			return chunkFrames(t, 1, header, body, 15)[:3] // 10 + 6 + 15 * 3 > 50
+		}, ErrMessageTooLarge},
+		{"pending too large", func(r *Reassembler) {
+			r.WithMaxPending(100, MaxPendingStreams)
+		}, func(t *testing.T) []*Frame {
+			frames1 := chunkFrames(t, 1, header, body, 30)
+			frames2 := chunkFrames(t, 2, header, body, 30)
+			return []*Frame{frames1[0], frames2[0], frames1[1]} // (10 + 6 + 30) * 2 + 30 > 100
+		}, ErrMessageTooLarge},
+		{"too many streams", func(r *Reassembler) {
+			r.WithMaxPending(MaxPendingSize, 2)
+		}, func(t *testing.T) []*Frame {
+			return []*Frame{
+				chunkFrames(t, 1, header, body, 30)[0],
+				chunkFrames(t, 2, header, body, 30)[0],
+				chunkFrames(t, 3, header, body, 30)[0],
+			}
+		}, ErrMessageTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReassembler(0)
+			if tt.setup != nil {
+				tt.setup(r)
+			}
+
+			frames := tt.add(t)
+			for i, f := range frames {
+				streamID := f.Head.StreamID
+				msg, err := r.Add(f)
+				if i < len(frames)-1 {
+					if err != nil || msg != nil {
+						t.Fatalf("frame %d: unexpected message or error %v", i, err)
+					}
+					continue
+				}
+
+				if err != tt.err {
+					t.Fatalf("Add error = %v, want %v", err, tt.err)
+				}
+
+				if _, ok := r.streams[streamID]; ok {
+					t.Fatal("the stream is not discarded after error")
+				}
+			}
+
+			// pending size is the total size of the streams left.
+			var pending int64
+			for _, msg := range r.streams {
+				pending += int64(len(msg.buf))
+			}
+
+			if pending != r.pendingSize || pending > r.maxPendingSize {
+				t.Fatalf("pending size %d mismatch with streams %d", r.pendingSize, pending)
+			}
+		})
+	}
+}
+
+func TestReassemblerEviction(t *testing.T) {
+	header, body := []byte("header"), bytes.Repeat([]byte("0123456789"), 10)
+
+	r := NewReassembler(0)
+	r.WithMaxPending(MaxPendingSize, 1)
+	r.WithStreamTimeout(10 * time.Millisecond)
+
+	frames1 := chunkFrames(t, 1, header, body, 30)
+	frames2 := chunkFrames(t, 2, header, body, 30)
+
+	if _, err := r.Add(frames1[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stream limit is reached before stream 1 times out.
+	if _, err := r.Add(frames2[0]); err != ErrMessageTooLarge {
+		t.Fatalf("Add error = %v, want %v", err, ErrMessageTooLarge)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	frames2 = chunkFrames(t, 2, header, body, 30)
+	for i, f := range frames2 {
+		msg, err := r.Add(f)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+
+		if i == len(frames2)-1 && (msg == nil || !bytes.Equal(msg.Body, body)) {
+			t.Fatal("message of stream 2 is not reassembled")
+		}
+	}
+
+	if len(r.streams) != 0 || r.pendingSize != 0 {
+		t.Fatal("the abandoned stream 1 is not evicted")
+	}
+
+	// the rest chunks of the evicted stream are out of order.
+	if _, err := r.Add(frames1[1]); err != ErrChunkSequence {
+		t.Fatalf("Add error = %v, want %v", err, ErrChunkSequence)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	header, body := []byte("header"), bytes.Repeat([]byte("0123456789"), 10)
+
+	buf := bytes.Buffer{}
+	h := NewFrameHead()
+	if _, err := h.WriteChunks(&buf, 1, header, body, 15); err != nil {
+		t.Fatal(err)
+	}
+
+	normal, err := h.Construct(header, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(normal)
+
+	fr := NewFrameReader(&buf)
+	for i := 0; i < 2; i++ {
+		msg, err := fr.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(msg.Header, header) || !bytes.Equal(msg.Body, body) {
+			t.Fatalf("message %d mismatch", i)
+		}
+		msg.Release()
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"sync"
+)
+
+// Codec packs and unpacks the frame of a protocol, it's goroutine-safe.
+type Codec interface {
+	// Encode packs the body into a whole frame, the frame header is built from msg.
+	Encode(msg *Msg, body []byte) ([]byte, error)
+
+	// Decode unpacks the whole frame, fills msg by the frame header, and returns the body.
+	Decode(msg *Msg, frame []byte) ([]byte, error)
+}
+
+var (
+	serverCodecs = map[string]Codec{}
+	clientCodecs = map[string]Codec{}
+	lockCodecs   = sync.RWMutex{}
+)
+
+// RegisterCodec registers the server codec and client codec of protocol name.
+func RegisterCodec(name string, serverCodec, clientCodec Codec) {
+	lockCodecs.Lock()
+	serverCodecs[name] = serverCodec
+	clientCodecs[name] = clientCodec
+	lockCodecs.Unlock()
+}
+
+// GetServerCodec returns the server codec of protocol name.
+func GetServerCodec(name string) Codec {
+	lockCodecs.RLock()
+	c := serverCodecs[name]
+	lockCodecs.RUnlock()
+	return c
+}
+
+// GetClientCodec returns the client codec of protocol name.
+func GetClientCodec(name string) Codec {
+	lockCodecs.RLock()
+	c := clientCodecs[name]
+	lockCodecs.RUnlock()
+	return c
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"github.com/horm-database/common/compress"
+	"github.com/horm-database/common/consts"
+)
+
+// CompressBody compresses the body by compress type and dictionary of msg. The server codec answers
+// with the compress type and dictionary of request, so the handler should compress the response body by them.
+func CompressBody(msg *Msg, body []byte) ([]byte, error) {
+	return compress.CompressWithDict(msg.CompressType(), msg.CompressDict(), body)
+}
+
+// DecompressBody decompresses the body by compress type and dictionary of msg.
+func DecompressBody(msg *Msg, body []byte) ([]byte, error) {
+	return compress.DecompressWithDict(msg.CompressType(), msg.CompressDict(), body)
+}
+
+// CompressBodyByPolicy compresses the server response body by compress type of msg according to the
+// policy. The compress type of msg, which is negotiated by request, is kept as it is, if the body is
+// left uncompressed by the policy, such as a small body, msg is marked by WithRespUncompressed, so that
+// the server codec answers with CompressTypeNone for this response only. The body is compressed by
+// dictionary regardless of the policy if msg has compress dictionary, since dictionary compression
+// is for small payloads.
+//
+// It is for the response path of server only. The request body should be compressed by CompressBody,
+// since the server answers with the compress type of request.
+func CompressBodyByPolicy(msg *Msg, p *compress.Policy, body []byte) ([]byte, error) {
+	if msg.CompressDict() != 0 {
+		msg.WithRespUncompressed(false)
+		return CompressBody(msg, body)
+	}
+
+	out, compressType, err := p.Compress(msg.CompressType(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.WithRespUncompressed(compressType == consts.CompressTypeNone)
+	return out, nil
+}
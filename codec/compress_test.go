@@ -0,0 +1,73 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/horm-database/common/compress"
+	"github.com/horm-database/common/consts"
+	"github.com/horm-database/common/proto"
+)
+
+func TestCompressBodyByPolicy(t *testing.T) {
+	_, msg := NewMessage(context.Background())
+	defer RecycleMessage(msg)
+
+	msg.WithCompressType(consts.CompressTypeGzip) // negotiated by request.
+	p := compress.NewPolicy("test")
+
+	tests := []struct {
+		name     string
+		body     []byte
+		compress uint32 // compress type of response header
+	}{
+		{"small body skipped", []byte("small"), consts.CompressTypeNone},
+		{"large body compressed", bytes.Repeat([]byte("large"), p.Threshold), consts.CompressTypeGzip},
+		{"small body after large one", []byte("small"), consts.CompressTypeNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := CompressBodyByPolicy(msg, p, tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if msg.CompressType() != consts.CompressTypeGzip {
+				t.Fatalf("negotiated compress type is changed to %d", msg.CompressType())
+			}
+
+			if _, err = (&HormServerCodec{}).Encode(msg, body); err != nil {
+				t.Fatal(err)
+			}
+
+			rsp := msg.ServerRespHead().(*proto.ResponseHeader)
+			if rsp.GetCompress() != tt.compress {
+				t.Fatalf("compress type of response = %d, want %d", rsp.GetCompress(), tt.compress)
+			}
+
+			out, err := compress.DecompressBy(rsp.GetCompress(), body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(out, tt.body) {
+				t.Fatal("decompressed body mismatch")
+			}
+		})
+	}
+}
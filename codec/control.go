@@ -0,0 +1,132 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	ControlTypePing   = 1 // ping, the peer must reply pong with the same data
+	ControlTypePong   = 2 // pong
+	ControlTypeGoAway = 3 // goaway, the peer should not send new requests on the connection
+
+	// ControlTypeVersionNotSupported replies the frame of unsupported version, its data
+	// is frame type + the rejected version + versions supported, see NewVersionNotSupportedFrame.
+	ControlTypeVersionNotSupported = 4
+
+	goAwayPayloadLen = 13 // control type + last request id + reason
+)
+
+const ( // reason code of goaway
+	GoAwayShutdown      = 0 // server is shutting down gracefully
+	GoAwayOverload      = 1 // server is overloaded
+	GoAwayProtocolError = 2 // peer violates the protocol
+	GoAwayIdleTimeout   = 3 // connection is idle for too long
+)
+
+var (
+	ErrControlFrame = errors.New("invalid control frame")
+)
+
+// ControlFrame is the payload of control frame, it is encoded as the body of
+// FrameTypeControl frame, which is control type + data for ping and pong,
+// control type + last request id + reason + data for goaway.
+type ControlFrame struct {
+	ControlType   uint8  // ping, pong or goaway
+	LastRequestID uint64 // id of the last request processed, only for goaway
+	Reason        uint32 // reason code, only for goaway
+	Data          []byte // opaque data of ping and pong, debug message of goaway, or versions
+}
+
+// NewPingFrame create a ping control frame with opaque data.
+func NewPingFrame(data []byte) *ControlFrame {
+	return &ControlFrame{ControlType: ControlTypePing, Data: data}
+}
+
+// NewPongFrame create a pong control frame, the data must be the same as ping.
+func NewPongFrame(data []byte) *ControlFrame {
+	return &ControlFrame{ControlType: ControlTypePong, Data: data}
+}
+
+// NewGoAwayFrame create a goaway control frame, requests with id larger than lastRequestID
+// are not processed, and can be retried on other connections.
+func NewGoAwayFrame(lastRequestID uint64, reason uint32, msg string) *ControlFrame {
+	return &ControlFrame{
+		ControlType:   ControlTypeGoAway,
+		LastRequestID: lastRequestID,
+		Reason:        reason,
+		Data:          []byte(msg),
+	}
+}
+
+// Construct constructs bytes body for the whole control frame.
+func (c *ControlFrame) Construct() ([]byte, error) {
+	return newControlFrameHead().Construct(nil, c.payload())
+}
+
+// WriteFrame writes the whole control frame to w.
+func (c *ControlFrame) WriteFrame(w io.Writer) (int64, error) {
+	return newControlFrameHead().WriteFrame(w, nil, c.payload())
+}
+
+// Control parses the control frame read by FrameReader.
+func (f *Frame) Control() (*ControlFrame, error) {
+	if f.FrameType != FrameTypeControl || len(f.Body) == 0 {
+		return nil, ErrControlFrame
+	}
+
+	c := &ControlFrame{ControlType: f.Body[0]}
+
+	switch c.ControlType {
+	case ControlTypePing, ControlTypePong, ControlTypeVersionNotSupported:
+		c.Data = append([]byte(nil), f.Body[1:]...)
+	case ControlTypeGoAway:
+		if len(f.Body) < goAwayPayloadLen {
+			return nil, ErrControlFrame
+		}
+		c.LastRequestID = binary.BigEndian.Uint64(f.Body[1:9])
+		c.Reason = binary.BigEndian.Uint32(f.Body[9:13])
+		c.Data = append([]byte(nil), f.Body[goAwayPayloadLen:]...)
+	default:
+		return nil, ErrControlFrame
+	}
+
+	return c, nil
+}
+
+func (c *ControlFrame) payload() []byte {
+	if c.ControlType != ControlTypeGoAway {
+		payload := make([]byte, 1+len(c.Data))
+		payload[0] = c.ControlType
+		copy(payload[1:], c.Data)
+		return payload
+	}
+
+	payload := make([]byte, goAwayPayloadLen+len(c.Data))
+	payload[0] = c.ControlType
+	binary.BigEndian.PutUint64(payload[1:9], c.LastRequestID)
+	binary.BigEndian.PutUint32(payload[9:13], c.Reason)
+	copy(payload[goAwayPayloadLen:], c.Data)
+	return payload
+}
+
+func newControlFrameHead() *FrameHead {
+	return &FrameHead{
+		FrameType: FrameTypeControl,
+		Version:   ControlVersion,
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestControlFrameRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame *ControlFrame
+	}{
+		{"ping", NewPingFrame([]byte("ping data"))},
+		{"ping without data", NewPingFrame(nil)},
+		{"pong", NewPongFrame([]byte("ping data"))},
+		{"goaway", NewGoAwayFrame(1<<40+7, GoAwayOverload, "server overload")},
+		{"goaway without message", NewGoAwayFrame(0, GoAwayShutdown, "")},
+		{"version not supported", NewVersionNotSupportedFrame(&VersionError{
+			FrameType: FrameTypeSignature,
+			Version:   SignVersionHMAC + 1,
+			Supported: []uint8{SignVersion, SignVersionHMAC},
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if _, err := tt.frame.WriteFrame(buf); err != nil {
+				t.Fatal(err)
+			}
+
+			constructed, err := tt.frame.Construct()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(buf.Bytes(), constructed) {
+				t.Fatal("frame written is different from the frame constructed")
+			}
+
+			f, err := NewFrameReader(buf).ReadFrame()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Release()
+
+			if f.FrameType != FrameTypeControl || f.Head.Version != ControlVersion {
+				t.Fatalf("frame type = %d version = %d, want control frame", f.FrameType, f.Head.Version)
+			}
+
+			c, err := f.Control()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.ControlType != tt.frame.ControlType || c.LastRequestID != tt.frame.LastRequestID ||
+				c.Reason != tt.frame.Reason || !bytes.Equal(c.Data, tt.frame.Data) {
+				t.Fatalf("control frame = %+v, want %+v", c, tt.frame)
+			}
+
+			if c.ControlType != ControlTypeVersionNotSupported {
+				return
+			}
+
+			versionErr, err := c.VersionError()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, _ := tt.frame.VersionError()
+			if !reflect.DeepEqual(versionErr, want) {
+				t.Fatalf("VersionError = %v, want %v", versionErr, want)
+			}
+		})
+	}
+}
+
+func TestControlFrameMalformed(t *testing.T) {
+	goAway, err := NewGoAwayFrame(1, GoAwayIdleTimeout, "idle").Construct()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	normal, err := NewFrameHead().Construct(benchHeader, benchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		input   []byte
+		readErr error
+	}{
+		{"empty payload", constructControl(t, nil), nil},
+		{"unknown control type", constructControl(t, []byte{ControlTypeVersionNotSupported + 1}), nil},
+		{"truncated goaway payload", constructControl(t, []byte{ControlTypeGoAway, 0, 0, 0, 0, 0, 0, 0, 1}), nil},
+		{"goaway payload without reason", constructControl(t, goAway[FrameHeadLen:FrameHeadLen+9]), nil},
+		{"not control frame", normal, nil},
+		{"truncated control frame", goAway[:len(goAway)-1], io.ErrUnexpectedEOF},
+		{"truncated control head", goAway[:FrameHeadLen-1], io.EOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFrameReader(bytes.NewReader(tt.input)).ReadFrame()
+			if tt.readErr != nil {
+				if !errors.Is(err, tt.readErr) {
+					t.Fatalf("ReadFrame error = %v, want %v", err, tt.readErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Release()
+
+			if c, err := f.Control(); !errors.Is(err, ErrControlFrame) {
+				t.Fatalf("Control = %+v, %v, want %v", c, err, ErrControlFrame)
+			}
+		})
+	}
+}
+
+func TestControlFrameVersionErrorMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame *ControlFrame
+	}{
+		{"not version not supported", NewPingFrame([]byte{FrameTypeNormal, ProtocolVersion})},
+		{"empty data", &ControlFrame{ControlType: ControlTypeVersionNotSupported}},
+		{"data without version", &ControlFrame{ControlType: ControlTypeVersionNotSupported,
+			Data: []byte{FrameTypeNormal}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if e, err := tt.frame.VersionError(); !errors.Is(err, ErrControlFrame) {
+				t.Fatalf("VersionError = %v, %v, want %v", e, err, ErrControlFrame)
+			}
+		})
+	}
+}
+
+// constructControl constructs control frame with raw payload.
+func constructControl(t *testing.T, payload []byte) []byte {
+	b, err := newControlFrameHead().Construct(nil, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
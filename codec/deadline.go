@@ -0,0 +1,114 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/horm-database/common/errs"
+	"github.com/horm-database/common/proto"
+)
+
+// DeadlineMargin is the default safety margin subtracted from the remaining budget when calling
+// downstream, which leaves time for the network round trip and the handling of response.
+var DeadlineMargin = 5 * time.Millisecond
+
+// RemainingBudget returns the remaining time budget of the request minus margin, which is the
+// remaining time before ctx deadline, and no more than the remaining request timeout of the msg
+// of ctx, that is the request timeout minus the time elapsed since its ReceiveTime. The request
+// timeout is taken as remaining entirely if ReceiveTime is not set. It returns 0 if neither of
+// them is set, which means no limit. errs.ErrClientTimeout error is returned if the budget is
+// exhausted, and the error of ctx is translated by ContextError.
+func RemainingBudget(ctx context.Context, margin time.Duration) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, ContextError(err)
+	}
+
+	var budget time.Duration
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		budget = time.Until(deadline)
+	}
+
+	msg := Message(ctx)
+	if timeout := msg.RequestTimeout(); timeout > 0 {
+		if received := msg.ReceiveTime(); !received.IsZero() {
+			timeout -= time.Since(received)
+		}
+
+		if !hasDeadline || timeout < budget {
+			budget, hasDeadline = timeout, true
+		}
+	}
+
+	if !hasDeadline {
+		return 0, nil
+	}
+
+	// the timeout of header is in ms, less than 1ms would be taken as no limit by downstream.
+	if budget-margin < time.Millisecond {
+		return 0, errs.Newf(errs.ErrClientTimeout,
+			"deadline budget exhausted, remaining %s, margin %s", budget, margin)
+	}
+
+	return budget - margin, nil
+}
+
+// ApplyBudget sets the remaining budget of ctx minus margin as the request timeout of msg, which is
+// written into the Timeout of outgoing request header by client codec. msg should be the client msg
+// for the call to downstream, rather than the msg of ctx itself. The receive time of msg is set to
+// now, from which the budget counts.
+func ApplyBudget(ctx context.Context, msg *Msg, margin time.Duration) error {
+	budget, err := RemainingBudget(ctx, margin)
+	if err != nil {
+		return err
+	}
+
+	msg.WithRequestTimeout(budget)
+	msg.WithReceiveTime(time.Now())
+	return nil
+}
+
+// SetHTTPBudget writes the remaining budget of ctx minus margin into head-timeout http header in ms.
+func SetHTTPBudget(ctx context.Context, header http.Header, margin time.Duration) error {
+	budget, err := RemainingBudget(ctx, margin)
+	if err != nil {
+		return err
+	}
+
+	if budget > 0 {
+		header.Set(proto.HeaderTimeout, strconv.FormatInt(int64(budget/time.Millisecond), 10))
+	}
+	return nil
+}
+
+// ContextError translates the context error into errs error, context.DeadlineExceeded to
+// errs.ErrClientTimeout and context.Canceled to errs.ErrClientCanceled. Other errors are
+// returned as it is.
+func ContextError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return errs.Newf(errs.ErrClientTimeout, "request timeout: %v", err)
+	case errors.Is(err, context.Canceled):
+		return errs.Newf(errs.ErrClientCanceled, "request canceled: %v", err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/horm-database/common/errs"
+)
+
+func TestRemainingBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration // request timeout of msg
+		elapsed  time.Duration // time elapsed since the request was received
+		deadline time.Duration // ctx deadline from now, 0 means no deadline
+		min, max time.Duration
+		err      int
+	}{
+		{"no limit", 0, 0, 0, 0, 0, 0},
+		{"request timeout", 100 * time.Millisecond, 0, 0, 90 * time.Millisecond, 95 * time.Millisecond, 0},
+		{"elapsed subtracted", 100 * time.Millisecond, 60 * time.Millisecond, 0,
+			30 * time.Millisecond, 35 * time.Millisecond, 0},
+		{"ctx deadline earlier", 100 * time.Millisecond, 0, 50 * time.Millisecond,
+			40 * time.Millisecond, 45 * time.Millisecond, 0},
+		{"remaining timeout earlier", 100 * time.Millisecond, 80 * time.Millisecond, 50 * time.Millisecond,
+			10 * time.Millisecond, 15 * time.Millisecond, 0},
+		{"exhausted", 100 * time.Millisecond, 100 * time.Millisecond, 0, 0, 0, errs.ErrClientTimeout},
+		{"within margin", 100 * time.Millisecond, 96 * time.Millisecond, 0, 0, 0, errs.ErrClientTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, msg := NewMessage(context.Background())
+			defer RecycleMessage(msg)
+
+			msg.WithRequestTimeout(tt.timeout)
+			msg.WithReceiveTime(time.Now().Add(-tt.elapsed))
+
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			budget, err := RemainingBudget(ctx, DeadlineMargin)
+			if errs.Code(err) != tt.err {
+				t.Fatalf("RemainingBudget error = %v, want code %d", err, tt.err)
+			}
+
+			if budget < tt.min || budget > tt.max {
+				t.Fatalf("RemainingBudget = %s, want [%s, %s]", budget, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestApplyBudget(t *testing.T) {
+	ctx, msg := NewMessage(context.Background())
+	defer RecycleMessage(msg)
+
+	msg.WithRequestTimeout(100 * time.Millisecond)
+	msg.WithReceiveTime(time.Now().Add(-60 * time.Millisecond))
+
+	ctx, clientMsg := NewClientMessage(ctx)
+	defer RecycleMessage(clientMsg)
+
+	if err := ApplyBudget(ctx, clientMsg, DeadlineMargin); err != nil {
+		t.Fatal(err)
+	}
+
+	// the budget of client msg counts from now, rather than the receive time of server msg.
+	budget, err := RemainingBudget(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if budget < 30*time.Millisecond || budget > 35*time.Millisecond {
+		t.Fatalf("RemainingBudget of client msg = %s, want about 35ms", budget)
+	}
+}
+
+func TestDetachedBudget(t *testing.T) {
+	ctx, msg := NewMessage(context.Background())
+	defer RecycleMessage(msg)
+
+	// the request timeout of upstream has passed.
+	msg.WithRequestTimeout(100 * time.Millisecond)
+	msg.WithReceiveTime(time.Now().Add(-time.Second))
+
+	if _, err := RemainingBudget(ctx, DeadlineMargin); errs.Code(err) != errs.ErrClientTimeout {
+		t.Fatalf("RemainingBudget of original request error = %v, want timeout", err)
+	}
+
+	asyncCtx, cancel, asyncMsg := NewAsyncMessage(ctx, 10*time.Second)
+	defer cancel()
+	defer RecycleMessage(asyncMsg)
+
+	budget, err := RemainingBudget(asyncCtx, DeadlineMargin)
+	if err != nil {
+		t.Fatalf("RemainingBudget of async message error = %v", err)
+	}
+
+	if budget < 9*time.Second || budget > 10*time.Second {
+		t.Fatalf("RemainingBudget of async message = %s, want about 10s", budget)
+	}
+
+	cloneCtx := CloneContext(ctx)
+	defer RecycleMessage(Message(cloneCtx))
+
+	if budget, err = RemainingBudget(cloneCtx, DeadlineMargin); err != nil || budget != 0 {
+		t.Fatalf("RemainingBudget of cloned context = %s, %v, want no limit", budget, err)
+	}
+}
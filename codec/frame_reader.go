@@ -0,0 +1,310 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+)
+
+const (
+	defaultReaderSize  = 4 * 1024  // default buffer size of frame reader.
+	maxPooledFrameSize = 64 * 1024 // frames larger than it will not be put back into pool.
+	maxFrameHeadLen    = HMACSignFrameHeadLen + math.MaxUint8
+)
+
+// Frame is a complete frame read from the connection.
+type Frame struct {
+	FrameType   uint8
+	Head        FrameHead        // valid when FrameType is FrameTypeNormal or FrameTypeControl
+	SignHead    SignFrameHead    // valid when FrameType is FrameTypeSignature
+	EncryptHead EncryptFrameHead // valid when FrameType is FrameTypeEncrypt
+
+	// Header is the rpc header of normal frame, it is nil for signature and encrypt frame.
+	Header []byte
+	// Body is the body of normal frame, the signed frame body of signature frame,
+	// the encrypted frame body of encrypt frame or the payload of control frame.
+	Body []byte
+
+	buf []byte // the whole frame, Header and Body are slices of it.
+}
+
+var framePool = sync.Pool{
+	New: func() interface{} {
+		return &Frame{}
+	},
+}
+
+// Bytes returns the whole frame, including frame head.
+func (f *Frame) Bytes() []byte {
+	return f.buf
+}
+
+// Release puts the frame back into pool, Header, Body and Bytes
+// of the frame must not be used after release.
+func (f *Frame) Release() {
+	if f == nil {
+		return
+	}
+
+	buf := f.buf
+	*f = Frame{}
+	if cap(buf) <= maxPooledFrameSize {
+		f.buf = buf[:0]
+	}
+	framePool.Put(f)
+}
+
+// grow returns the frame buffer with length n.
+func (f *Frame) grow(n int) []byte {
+	if cap(f.buf) < n {
+		f.buf = make([]byte, n)
+	}
+	f.buf = f.buf[:n]
+	return f.buf
+}
+
+// FrameReader reads frames of all frame types from a reader, such as net.Conn.
+type FrameReader struct {
+	reader      *bufio.Reader
+	reassembler *Reassembler
+	versions    FrameVersions // versions accepted, all versions supported by this package if nil.
+}
+
+// NewFrameReader create a frame reader.
+func NewFrameReader(r io.Reader) *FrameReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok || br.Size() < maxFrameHeadLen {
+		br = bufio.NewReaderSize(r, defaultReaderSize)
+	}
+	return &FrameReader{reader: br}
+}
+
+// WithVersions sets the versions accepted by the reader.
+func (fr *FrameReader) WithVersions(versions FrameVersions) {
+	fr.versions = versions
+}
+
+// ReadFrame reads a complete frame, the version and length of frame are validated
+// before the frame buffer is allocated. The returned frame should be released
+// by Release after it is no longer used.
+//
+// The frame of unsupported version is skipped and a *VersionError is returned,
+// so that the server can reply it by NewVersionNotSupportedFrame and keep
+// reading the following frames on the connection.
+func (fr *FrameReader) ReadFrame() (*Frame, error) {
+	head, err := fr.peekHead()
+	if err != nil {
+		return nil, err
+	}
+
+	totalLen, err := checkFrameHead(head)
+	if err != nil {
+		return nil, err
+	}
+
+	headLen := len(head)
+
+	f := framePool.Get().(*Frame)
+	buf := f.grow(totalLen)
+	copy(buf, head)
+	_, _ = fr.reader.Discard(headLen)
+	if _, err = io.ReadFull(fr.reader, buf[headLen:]); err != nil {
+		f.Release()
+		return nil, err
+	}
+
+	f.FrameType = buf[0]
+	switch f.FrameType {
+	case FrameTypeNormal, FrameTypeControl:
+		f.Head.Extract(buf)
+		f.Header = buf[headLen : headLen+int(f.Head.HeaderLen)]
+		f.Body = buf[headLen+int(f.Head.HeaderLen):]
+		if err = f.Head.Verify(f.Header, f.Body); err != nil {
+			f.Release()
+			return nil, err
+		}
+	case FrameTypeSignature:
+		f.SignHead.Extract(buf)
+		f.Body = buf[headLen:]
+	case FrameTypeEncrypt:
+		f.EncryptHead.Extract(buf)
+		f.Body = buf[headLen:]
+	}
+
+	return f, nil
+}
+
+// peekHead peeks the complete frame head, which is valid until the next read.
+func (fr *FrameReader) peekHead() ([]byte, error) {
+	prefix, err := fr.reader.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	if fr.versions != nil && !fr.versions.Support(prefix[0], prefix[1]) ||
+		fr.versions == nil && !supportVersion(prefix[0], prefix[1]) {
+		return nil, fr.skipUnsupported(prefix[0], prefix[1])
+	}
+
+	headLen, err := frameHeadLen(prefix[0], prefix[1])
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := fr.reader.Peek(headLen)
+	if err != nil {
+		return nil, err
+	}
+
+	if head[0] == FrameTypeNormal || head[0] == FrameTypeControl {
+		if extLen := frameHeadExtLen(binary.BigEndian.Uint16(head[8:10])); extLen > 0 {
+			return fr.reader.Peek(headLen + extLen)
+		}
+	}
+
+	if head[0] == FrameTypeSignature && head[1] == SignVersionHMAC {
+		// the signature length of hmac sign frame head is variable.
+		return fr.reader.Peek(headLen + int(head[headLen-1]))
+	}
+
+	return head, nil
+}
+
+// skipUnsupported skips the frame of unsupported version, and returns *VersionError. It
+// relies on that the offset of TotalLen is the same for all versions of the frame type.
+func (fr *FrameReader) skipUnsupported(frameType, version uint8) error {
+	if frameType > FrameTypeControl {
+		return ErrFrameType
+	}
+
+	head, err := fr.reader.Peek(8)
+	if err != nil {
+		return err
+	}
+
+	var totalLen uint32
+	if frameType == FrameTypeNormal || frameType == FrameTypeControl {
+		totalLen = binary.BigEndian.Uint32(head[4:8])
+	} else {
+		totalLen = binary.BigEndian.Uint32(head[3:7])
+	}
+
+	if int64(totalLen) > int64(MaxFrameSize) {
+		return ErrFrameTooLarge
+	}
+
+	if totalLen < 8 {
+		return ErrFrameTotalLen
+	}
+
+	if _, err = fr.reader.Discard(int(totalLen)); err != nil {
+		return err
+	}
+
+	supported := fr.versions
+	if supported == nil {
+		supported = SupportedFrameVersions()
+	}
+
+	return &VersionError{
+		FrameType: frameType,
+		Version:   version,
+		Supported: supported[frameType],
+	}
+}
+
+// frameHeadLen returns the fixed head length of the frame type and version.
+func frameHeadLen(frameType, version uint8) (int, error) {
+	if !supportVersion(frameType, version) {
+		if frameType > FrameTypeControl {
+			return 0, ErrFrameType
+		}
+		return 0, ErrFrameVersion
+	}
+
+	switch frameType {
+	case FrameTypeNormal, FrameTypeControl:
+		return FrameHeadLen, nil
+	case FrameTypeSignature:
+		if version == SignVersionHMAC {
+			return HMACSignFrameHeadLen, nil
+		}
+		return SignFrameHeadLen, nil
+	default:
+		return EncryptFrameHeadLen, nil
+	}
+}
+
+// checkFrameHead checks the length of frame head, returns total length of frame.
+func checkFrameHead(head []byte) (int, error) {
+	var totalLen uint32
+	if head[0] == FrameTypeNormal || head[0] == FrameTypeControl {
+		totalLen = binary.BigEndian.Uint32(head[4:8])
+	} else {
+		totalLen = binary.BigEndian.Uint32(head[3:7])
+	}
+
+	if int64(totalLen) > int64(MaxFrameSize) {
+		return 0, ErrFrameTooLarge
+	}
+
+	if int(totalLen) < len(head) {
+		return 0, ErrFrameTotalLen
+	}
+
+	if head[0] == FrameTypeNormal || head[0] == FrameTypeControl {
+		headerLen := binary.BigEndian.Uint16(head[2:4])
+		if int(headerLen) > int(totalLen)-len(head) {
+			return 0, ErrFrameHeaderLen
+		}
+	}
+
+	return int(totalLen), nil
+}
+
+// Open returns the frame body of signature frame after its signature has been verified,
+// or the decrypted frame body of encrypt frame. Normal frame is returned as it is.
+func (f *Frame) Open(token []byte) ([]byte, error) {
+	switch f.FrameType {
+	case FrameTypeSignature:
+		if err := f.SignHead.Verify(token, f.Body); err != nil {
+			return nil, err
+		}
+		return f.Body, nil
+	case FrameTypeEncrypt:
+		return f.EncryptHead.Open(token, f.Body)
+	default:
+		return f.buf, nil
+	}
+}
+
+// supportVersion returns whether the version of frame type is supported by this package.
+func supportVersion(frameType, version uint8) bool {
+	switch frameType {
+	case FrameTypeNormal:
+		return version == ProtocolVersion
+	case FrameTypeSignature:
+		return version == SignVersion || version == SignVersionHMAC
+	case FrameTypeEncrypt:
+		return version == EncryptVersion || version == EncryptVersionGCM
+	case FrameTypeControl:
+		return version == ControlVersion
+	default:
+		return false
+	}
+}
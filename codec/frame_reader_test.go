@@ -0,0 +1,181 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestFrameReader(t *testing.T) {
+	h := NewFrameHead()
+	h.EnableChecksum()
+	normal, err := h.Construct(benchHeader, benchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := NewHMACSignFrameHead().Construct(1, benchToken, benchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := NewGCMEncryptFrameHead().Construct(1, benchToken, benchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	badChecksum := append([]byte{}, normal...)
+	badChecksum[len(badChecksum)-1] ^= 1
+
+	smallTotalLen := append([]byte{}, normal...)
+	binary.BigEndian.PutUint32(smallTotalLen[4:8], FrameHeadLen-1)
+
+	largeHeaderLen := append([]byte{}, normal...)
+	binary.BigEndian.PutUint16(largeHeaderLen[2:4], uint16(len(benchHeader)+len(benchBody)+1))
+
+	tooLarge := append([]byte{}, normal...)
+	binary.BigEndian.PutUint32(tooLarge[4:8], uint32(MaxFrameSize)+1)
+
+	unknownType := append([]byte{}, normal...)
+	unknownType[0] = FrameTypeControl + 1
+
+	tests := []struct {
+		name      string
+		input     []byte
+		frameType uint8
+		err       error
+	}{
+		{"normal frame", normal, FrameTypeNormal, nil},
+		{"signature frame", signed, FrameTypeSignature, nil},
+		{"encrypt frame", encrypted, FrameTypeEncrypt, nil},
+		{"empty input", nil, 0, io.EOF},
+		{"truncated head", normal[:FrameHeadLen-1], 0, io.EOF},
+		{"truncated hmac signature", signed[:HMACSignFrameHeadLen+1], 0, io.EOF},
+		{"truncated body", normal[:len(normal)-1], 0, io.ErrUnexpectedEOF},
+		{"bad checksum", badChecksum, 0, ErrFrameChecksum},
+		{"total len smaller than head", smallTotalLen, 0, ErrFrameTotalLen},
+		{"header len overflows total len", largeHeaderLen, 0, ErrFrameHeaderLen},
+		{"frame too large", tooLarge, 0, ErrFrameTooLarge},
+		{"unknown frame type", unknownType, 0, ErrFrameType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFrameReader(bytes.NewReader(tt.input)).ReadFrame()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ReadFrame error = %v, want %v", err, tt.err)
+			}
+
+			if err != nil {
+				return
+			}
+			defer f.Release()
+
+			if f.FrameType != tt.frameType {
+				t.Fatalf("frame type = %d, want %d", f.FrameType, tt.frameType)
+			}
+
+			if !bytes.Equal(f.Bytes(), tt.input) {
+				t.Fatal("frame read is different from the frame written")
+			}
+
+			if _, err = f.Open(benchToken); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestFrameReaderUnsupportedVersion(t *testing.T) {
+	signed, err := NewSignFrameHead().Construct(1, benchToken, benchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsupported := append([]byte{}, signed...)
+	unsupported[1] = SignVersionHMAC + 1
+
+	normal, err := NewFrameHead().Construct(benchHeader, benchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		input    []byte
+		versions FrameVersions
+	}{
+		{"version unknown to package", unsupported, nil},
+		{"version not accepted by reader", signed, FrameVersions{
+			FrameTypeNormal:    {ProtocolVersion},
+			FrameTypeSignature: {SignVersionHMAC},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := NewFrameReader(bytes.NewReader(append(append([]byte{}, tt.input...), normal...)))
+			fr.WithVersions(tt.versions)
+
+			_, err := fr.ReadFrame()
+			var versionErr *VersionError
+			if !errors.As(err, &versionErr) || !errors.Is(err, ErrFrameVersion) {
+				t.Fatalf("ReadFrame error = %v, want *VersionError", err)
+			}
+
+			if versionErr.FrameType != FrameTypeSignature || versionErr.Version != tt.input[1] {
+				t.Fatalf("unexpected version error %v", versionErr)
+			}
+
+			// the unsupported frame is skipped, and the following frame is still readable.
+			f, err := fr.ReadFrame()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Release()
+
+			if !bytes.Equal(f.Bytes(), normal) {
+				t.Fatal("frame following the unsupported frame mismatch")
+			}
+		})
+	}
+}
+
+func TestFrameReaderControlVersion(t *testing.T) {
+	ping, err := NewPingFrame([]byte("ping")).Construct()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the versions without FrameTypeControl, such as the example of FrameVersions.
+	fr := NewFrameReader(bytes.NewReader(ping))
+	fr.WithVersions(FrameVersions{
+		FrameTypeNormal:    {ProtocolVersion},
+		FrameTypeSignature: {SignVersion, SignVersionHMAC},
+	})
+
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatalf("control frame is rejected: %v", err)
+	}
+	defer f.Release()
+
+	if f.FrameType != FrameTypeControl {
+		t.Fatalf("frame type = %d, want control frame", f.FrameType)
+	}
+}
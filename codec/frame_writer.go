@@ -0,0 +1,109 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+var (
+	headBufPool = sync.Pool{
+		New: func() interface{} {
+			buf := make([]byte, maxFrameHeadLen)
+			return &buf
+		},
+	}
+
+	frameBufPool = sync.Pool{
+		New: func() interface{} {
+			buf := make([]byte, 0, defaultReaderSize)
+			return &buf
+		},
+	}
+)
+
+// WriteFrame writes the whole frame to w, it is equivalent to writing the result of Construct,
+// but header and body are written by net.Buffers (writev for net.Conn) without being copied.
+func (h *FrameHead) WriteFrame(w io.Writer, header, body []byte) (int64, error) {
+	if err := h.prepare(header, body); err != nil {
+		return 0, err
+	}
+
+	head := headBufPool.Get().(*[]byte)
+	defer headBufPool.Put(head)
+
+	headBuf := (*head)[:h.HeadLen()]
+	h.encode(headBuf)
+
+	bufs := net.Buffers{headBuf, header, body}
+	return bufs.WriteTo(w)
+}
+
+// WriteFrame writes the whole frame to w, it is equivalent to writing the result of Construct,
+// but frame body is written by net.Buffers (writev for net.Conn) without being copied.
+func (h *SignFrameHead) WriteFrame(w io.Writer, workspaceID int, token, frameBody []byte) (int64, error) {
+	if err := h.prepare(workspaceID, token, frameBody); err != nil {
+		return 0, err
+	}
+
+	head := headBufPool.Get().(*[]byte)
+	defer headBufPool.Put(head)
+
+	headLen := h.HeadLen()
+	headBuf := (*head)[:headLen]
+	h.encode(headBuf)
+	copy(headBuf[headLen-len(h.Sign):], h.Sign)
+
+	bufs := net.Buffers{headBuf, frameBody}
+	return bufs.WriteTo(w)
+}
+
+// WriteFrame writes the whole frame to w, it is equivalent to writing the result of Construct,
+// but the encrypted frame body is not copied into a new frame buffer.
+func (h *EncryptFrameHead) WriteFrame(w io.Writer, workspaceID int, token, frameBody []byte) (int64, error) {
+	h.WorkspaceID = uint32(workspaceID)
+
+	if h.Version == EncryptVersionGCM {
+		frameBuf := frameBufPool.Get().(*[]byte)
+		defer frameBufPool.Put(frameBuf)
+
+		buf, err := h.sealGCM(*frameBuf, token, frameBody)
+		if err != nil {
+			return 0, err
+		}
+
+		if cap(buf) <= maxPooledFrameSize {
+			*frameBuf = buf[:0]
+		}
+
+		n, err := w.Write(buf)
+		return int64(n), err
+	}
+
+	encryptFrameBody, err := h.encryptCBC(token, frameBody)
+	if err != nil {
+		return 0, err
+	}
+
+	head := headBufPool.Get().(*[]byte)
+	defer headBufPool.Put(head)
+
+	headBuf := (*head)[:EncryptFrameHeadLen]
+	h.encode(headBuf)
+
+	bufs := net.Buffers{headBuf, encryptFrameBody}
+	return bufs.WriteTo(w)
+}
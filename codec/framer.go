@@ -4,23 +4,48 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash/crc32"
+	"io"
 	"math"
+	"time"
+
+	"github.com/horm-database/common/errs"
 )
 
 var (
 	MaxFrameSize = 10 * 1024 * 1024 // max size of frame.
+
+	// SignTimeWindow is the max clock skew allowed between the timestamp
+	// of hmac signature frame and local time.
+	SignTimeWindow = 5 * time.Minute
 )
 
 var (
 	ErrHeadOverflowsUint16 = errors.New("head len overflows uint16")
 	ErrHeadOverflowsUint32 = errors.New("total len overflows uint32")
 	ErrFrameTooLarge       = errors.New("length of frame is larger than MaxFrameSize")
+	ErrFrameTotalLen       = errors.New("total len of frame is smaller than its head")
+	ErrFrameHeaderLen      = errors.New("header len of frame overflows its total len")
+	ErrFrameType           = errors.New("unknown frame type")
+	ErrFrameVersion        = errors.New("unsupported frame version")
+
+	ErrFrameLenMismatch  = errs.New(errs.ErrServerReadFrame, "length of frame mismatch with its total len")
+	ErrFrameBadSignature = errs.New(errs.ErrServerReadFrame, "signature of frame mismatch")
+	ErrFrameBadPadding   = errs.New(errs.ErrServerReadFrame, "invalid pkcs7 padding of encrypt frame")
+	ErrFrameBadCipher    = errs.New(errs.ErrServerReadFrame, "invalid cipher text of encrypt frame")
+	ErrFrameAuthFailed   = errs.New(errs.ErrServerReadFrame, "message authentication of encrypt frame failed")
+	ErrFrameExpired      = errs.New(errs.ErrServerReadFrame, "timestamp of signature frame is out of SignTimeWindow")
+	ErrFrameChecksum     = errs.New(errs.ErrServerReadFrame, "checksum of frame mismatch")
 )
 
 const (
@@ -29,18 +54,42 @@ const (
 	FrameHeadLen    = 10 // total length of frame head
 	ProtocolVersion = 1  // protocol version  v1
 
+	// FrameFlagChecksum is the flag bit in FrameHead.Reserved, when it is set, a 4 bytes
+	// crc32c checksum of header and body follows the frame head.
+	FrameFlagChecksum = 1 << 0
+	FrameChecksumLen  = 4 // length of checksum
+
+	// FrameFlagChunked is the flag bit in FrameHead.Reserved, when it is set, the frame is a
+	// chunk of a message larger than MaxFrameSize, a 4 bytes stream id and a 4 bytes sequence
+	// follow the frame head (and the checksum), header is only carried in the first chunk.
+	FrameFlagChunked = 1 << 1
+	// FrameFlagEndOfMessage is the flag bit in FrameHead.Reserved, it marks the last chunk.
+	FrameFlagEndOfMessage = 1 << 2
+	FrameChunkInfoLen     = 8 // length of stream id and sequence
+
 	// FrameTypeSignature mainly used for intercommunication between
 	// data in the same cloud through the bastion host.
 	FrameTypeSignature = 1  // signature frame
 	SignFrameHeadLen   = 43 // total length of sign frame head
-	SignVersion        = 1  // version of signature frame
+	SignVersion        = 1  // version of signature frame, md5(token+frame)
+	SignVersionHMAC    = 2  // version of signature frame, hmac-sha256 with timestamp
+
+	// HMACSignFrameHeadLen is the length of sign frame head of SignVersionHMAC,
+	// excluding the variable length signature that follows it.
+	HMACSignFrameHeadLen = 20
 
 	// FrameTypeEncrypt mainly used for android, iphone and
 	// other terminal devices to directly access workspace data,
 	// to prevent data from being hijacked.
 	FrameTypeEncrypt    = 2  // encrypt frame
 	EncryptFrameHeadLen = 11 // total length of sign frame head
-	EncryptVersion      = 1  // version of encrypt frame
+	EncryptVersion      = 1  // version of encrypt frame, aes-cbc
+	EncryptVersionGCM   = 2  // version of encrypt frame, aes-gcm with random nonce
+
+	// FrameTypeControl is used for connection management, such as heartbeat
+	// and graceful shutdown, it has the same head as FrameTypeNormal.
+	FrameTypeControl = 3 // control frame
+	ControlVersion   = 1 // version of control frame
 
 	ProtocolTypeRPC  = 1 // protocol type rpc
 	ProtocolTypeHTTP = 2 // protocol type http
@@ -52,9 +101,14 @@ type FrameHead struct {
 	Version   uint8  // version of protocol
 	HeaderLen uint16 // header length
 	TotalLen  uint32 // total length
-	Reserved  uint16
+	Reserved  uint16 // flags of frame, such as FrameFlagChecksum
+	Checksum  uint32 // crc32c checksum of header and body, only when FrameFlagChecksum is set
+	StreamID  uint32 // stream id of chunked message, only when FrameFlagChunked is set
+	Sequence  uint32 // sequence of chunk, starts from 0, only when FrameFlagChunked is set
 }
 
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
 func NewFrameHead() *FrameHead {
 	return &FrameHead{
 		FrameType: FrameTypeNormal,
@@ -62,6 +116,29 @@ func NewFrameHead() *FrameHead {
 	}
 }
 
+// EnableChecksum sets FrameFlagChecksum, so that the crc32c checksum of header and body
+// is carried in the frame, and verified by the decoding side.
+func (h *FrameHead) EnableChecksum() {
+	h.Reserved |= FrameFlagChecksum
+}
+
+// HeadLen returns the total length of frame head, including the checksum and chunk info.
+func (h *FrameHead) HeadLen() int {
+	return FrameHeadLen + frameHeadExtLen(h.Reserved)
+}
+
+// frameHeadExtLen returns length of the extension that follows the frame head.
+func frameHeadExtLen(flags uint16) int {
+	n := 0
+	if flags&FrameFlagChecksum != 0 {
+		n += FrameChecksumLen
+	}
+	if flags&FrameFlagChunked != 0 {
+		n += FrameChunkInfoLen
+	}
+	return n
+}
+
 // Extract extracts field values of the FrameHead from the buffer.
 func (h *FrameHead) Extract(buf []byte) {
 	h.FrameType = buf[0]
@@ -69,32 +146,94 @@ func (h *FrameHead) Extract(buf []byte) {
 	h.HeaderLen = binary.BigEndian.Uint16(buf[2:4])
 	h.TotalLen = binary.BigEndian.Uint32(buf[4:8])
 	h.Reserved = binary.BigEndian.Uint16(buf[8:10])
+
+	h.Checksum, h.StreamID, h.Sequence = 0, 0, 0
+
+	ext := buf[FrameHeadLen:]
+	if h.Reserved&FrameFlagChecksum != 0 {
+		h.Checksum = binary.BigEndian.Uint32(ext[:FrameChecksumLen])
+		ext = ext[FrameChecksumLen:]
+	}
+
+	if h.Reserved&FrameFlagChunked != 0 {
+		h.StreamID = binary.BigEndian.Uint32(ext[:4])
+		h.Sequence = binary.BigEndian.Uint32(ext[4:8])
+	}
 }
 
 // Construct constructs bytes body for the whole frame.
 func (h *FrameHead) Construct(header, body []byte) ([]byte, error) {
+	if err := h.prepare(header, body); err != nil {
+		return nil, err
+	}
+
+	// construct the buffer
+	headLen, headerLen := h.HeadLen(), len(header)
+	buf := make([]byte, h.TotalLen)
+	h.encode(buf)
+	copy(buf[headLen:headLen+headerLen], header)
+	copy(buf[headLen+headerLen:], body)
+	return buf, nil
+}
+
+// prepare checks the length of the frame, and sets HeaderLen, TotalLen and Checksum.
+func (h *FrameHead) prepare(header, body []byte) error {
 	headerLen := len(header)
 	if headerLen > math.MaxUint16 {
-		return nil, ErrHeadOverflowsUint16
+		return ErrHeadOverflowsUint16
 	}
-	totalLen := int64(FrameHeadLen) + int64(headerLen) + int64(len(body))
+	totalLen := int64(h.HeadLen()) + int64(headerLen) + int64(len(body))
 	if totalLen > int64(MaxFrameSize) {
-		return nil, ErrFrameTooLarge
+		return ErrFrameTooLarge
 	}
 	if totalLen > math.MaxUint32 {
-		return nil, ErrHeadOverflowsUint32
+		return ErrHeadOverflowsUint32
 	}
 
-	// construct the buffer
-	buf := make([]byte, totalLen)
+	h.HeaderLen = uint16(headerLen)
+	h.TotalLen = uint32(totalLen)
+	if h.Reserved&FrameFlagChecksum != 0 {
+		h.Checksum = checksum(header, body)
+	}
+	return nil
+}
+
+// encode writes field values of the FrameHead into the buffer.
+func (h *FrameHead) encode(buf []byte) {
 	buf[0] = h.FrameType
 	buf[1] = h.Version
-	binary.BigEndian.PutUint16(buf[2:4], uint16(headerLen))
-	binary.BigEndian.PutUint32(buf[4:8], uint32(totalLen))
+	binary.BigEndian.PutUint16(buf[2:4], h.HeaderLen)
+	binary.BigEndian.PutUint32(buf[4:8], h.TotalLen)
 	binary.BigEndian.PutUint16(buf[8:10], h.Reserved)
-	copy(buf[FrameHeadLen:FrameHeadLen+headerLen], header)
-	copy(buf[FrameHeadLen+headerLen:], body)
-	return buf, nil
+
+	ext := buf[FrameHeadLen:]
+	if h.Reserved&FrameFlagChecksum != 0 {
+		binary.BigEndian.PutUint32(ext[:FrameChecksumLen], h.Checksum)
+		ext = ext[FrameChecksumLen:]
+	}
+
+	if h.Reserved&FrameFlagChunked != 0 {
+		binary.BigEndian.PutUint32(ext[:4], h.StreamID)
+		binary.BigEndian.PutUint32(ext[4:8], h.Sequence)
+	}
+}
+
+// Verify verifies the checksum of header and body if FrameFlagChecksum is set.
+func (h *FrameHead) Verify(header, body []byte) error {
+	if h.Reserved&FrameFlagChecksum == 0 {
+		return nil
+	}
+
+	if checksum(header, body) != h.Checksum {
+		return ErrFrameChecksum
+	}
+
+	return nil
+}
+
+// checksum returns crc32c checksum of header and body.
+func checksum(header, body []byte) uint32 {
+	return crc32.Update(crc32.Checksum(header, castagnoliTable), castagnoliTable, body)
 }
 
 // SignFrameHead is head of signature of frame
@@ -104,7 +243,10 @@ type SignFrameHead struct {
 	ProtocolType uint8  // 1-rpc 2-http
 	TotalLen     uint32 // total length
 	WorkSpaceID  uint32 // workspace length
-	Sign         []byte // signature 32 个字节，md5(workspace_id+secret+frame)
+	Timestamp    uint64 // sign time in milliseconds, only for SignVersionHMAC
+	// Sign is signature of frame, for SignVersion, it's 32 bytes hex encoded md5(token+frame),
+	// for SignVersionHMAC, it's hmac-sha256(token, head+frame), its length is encoded in the head.
+	Sign []byte
 }
 
 func NewSignFrameHead() *SignFrameHead {
@@ -115,6 +257,23 @@ func NewSignFrameHead() *SignFrameHead {
 	}
 }
 
+// NewHMACSignFrameHead create a sign frame head of SignVersionHMAC.
+func NewHMACSignFrameHead() *SignFrameHead {
+	return &SignFrameHead{
+		FrameType:    FrameTypeSignature,
+		Version:      SignVersionHMAC,
+		ProtocolType: ProtocolTypeRPC,
+	}
+}
+
+// HeadLen returns the total length of sign frame head.
+func (h *SignFrameHead) HeadLen() int {
+	if h.Version == SignVersionHMAC {
+		return HMACSignFrameHeadLen + len(h.Sign)
+	}
+	return SignFrameHeadLen
+}
+
 // Extract extracts field values of the FrameHead from the buffer.
 func (h *SignFrameHead) Extract(buf []byte) {
 	h.FrameType = buf[0]
@@ -122,37 +281,155 @@ func (h *SignFrameHead) Extract(buf []byte) {
 	h.ProtocolType = buf[2]
 	h.TotalLen = binary.BigEndian.Uint32(buf[3:7])
 	h.WorkSpaceID = binary.BigEndian.Uint32(buf[7:11])
+
+	if h.Version == SignVersionHMAC {
+		h.Timestamp = binary.BigEndian.Uint64(buf[11:19])
+		h.Sign = buf[HMACSignFrameHeadLen : HMACSignFrameHeadLen+int(buf[19])]
+		return
+	}
+
+	h.Timestamp = 0
 	h.Sign = buf[11:43]
 }
 
 // Construct constructs bytes body for the whole frame.
 func (h *SignFrameHead) Construct(workspaceID int, token, frameBody []byte) ([]byte, error) {
+	if err := h.prepare(workspaceID, token, frameBody); err != nil {
+		return nil, err
+	}
+
+	// construct the buffer
+	headLen := h.HeadLen()
+	buf := make([]byte, h.TotalLen)
+	h.encode(buf)
+	copy(buf[headLen-len(h.Sign):headLen], h.Sign)
+	copy(buf[headLen:], frameBody)
+	return buf, nil
+}
+
+// prepare checks the length of the frame, and sets WorkSpaceID, TotalLen, Timestamp and Sign.
+func (h *SignFrameHead) prepare(workspaceID int, token, frameBody []byte) error {
 	h.WorkSpaceID = uint32(workspaceID)
 
-	h.TotalLen = uint32(SignFrameHeadLen) + uint32(len(frameBody))
-	if h.TotalLen > uint32(MaxFrameSize) {
-		return nil, ErrFrameTooLarge
+	if h.Version == SignVersionHMAC {
+		h.Timestamp = uint64(time.Now().UnixMilli())
+		h.Sign = make([]byte, sha256.Size) // reserve the signature length for head
 	}
 
-	if h.TotalLen > math.MaxUint32 {
-		return nil, ErrHeadOverflowsUint32
+	totalLen := int64(h.HeadLen()) + int64(len(frameBody))
+	if totalLen > int64(MaxFrameSize) {
+		return ErrFrameTooLarge
 	}
 
-	// construct the buffer
-	buf := make([]byte, h.TotalLen)
+	if totalLen > math.MaxUint32 {
+		return ErrHeadOverflowsUint32
+	}
+
+	h.TotalLen = uint32(totalLen)
+
+	if h.Version == SignVersionHMAC {
+		var head [HMACSignFrameHeadLen]byte
+		h.encode(head[:])
+		h.Sign = hmacSignature(token, head[:], frameBody)
+	} else {
+		h.Sign = signature(token, frameBody)
+	}
+
+	return nil
+}
+
+// Verify verifies the signature of the frame body with workspace token,
+// the timestamp of SignVersionHMAC must also be within SignTimeWindow.
+func (h *SignFrameHead) Verify(token, frameBody []byte) error {
+	if int64(h.TotalLen) != int64(h.HeadLen())+int64(len(frameBody)) {
+		return ErrFrameLenMismatch
+	}
+
+	switch h.Version {
+	case SignVersion:
+		if subtle.ConstantTimeCompare(h.Sign, signature(token, frameBody)) != 1 {
+			return ErrFrameBadSignature
+		}
+	case SignVersionHMAC:
+		head := make([]byte, HMACSignFrameHeadLen)
+		h.encode(head)
+		if !hmac.Equal(h.Sign, hmacSignature(token, head, frameBody)) {
+			return ErrFrameBadSignature
+		}
+
+		skew := time.Since(time.UnixMilli(int64(h.Timestamp)))
+		if skew > SignTimeWindow || skew < -SignTimeWindow {
+			return ErrFrameExpired
+		}
+	default:
+		return ErrFrameVersion
+	}
+
+	return nil
+}
+
+// VerifySignFrame extracts the signature frame head from the whole frame and verifies
+// its signature, returns the signature frame head and the signed frame body.
+func VerifySignFrame(buf, token []byte) (*SignFrameHead, []byte, error) {
+	if len(buf) < 2 {
+		return nil, nil, ErrFrameLenMismatch
+	}
+
+	headLen := SignFrameHeadLen
+	if buf[1] == SignVersionHMAC {
+		headLen = HMACSignFrameHeadLen
+		if len(buf) >= headLen {
+			headLen += int(buf[headLen-1])
+		}
+	}
+
+	if len(buf) < headLen {
+		return nil, nil, ErrFrameLenMismatch
+	}
+
+	h := &SignFrameHead{}
+	h.Extract(buf)
+
+	frameBody := buf[headLen:]
+	if err := h.Verify(token, frameBody); err != nil {
+		return nil, nil, err
+	}
+
+	return h, frameBody, nil
+}
+
+// encode writes field values of the SignFrameHead into the buffer, excluding the signature.
+func (h *SignFrameHead) encode(buf []byte) {
 	buf[0] = h.FrameType
 	buf[1] = h.Version
 	buf[2] = h.ProtocolType
 	binary.BigEndian.PutUint32(buf[3:7], h.TotalLen)
 	binary.BigEndian.PutUint32(buf[7:11], h.WorkSpaceID)
 
-	sumBytes := md5.Sum(append(token, frameBody...))
-	signature := make([]byte, hex.EncodedLen(len(sumBytes[:])))
-	_ = hex.Encode(signature, sumBytes[:])
-	copy(buf[11:43], signature)
+	if h.Version == SignVersionHMAC {
+		binary.BigEndian.PutUint64(buf[11:19], h.Timestamp)
+		buf[19] = uint8(len(h.Sign))
+	}
+}
 
-	copy(buf[SignFrameHeadLen:], frameBody)
-	return buf, nil
+// signature returns hex encoded md5(token+frameBody).
+func signature(token, frameBody []byte) []byte {
+	hash := md5.New()
+	_, _ = hash.Write(token)
+	_, _ = hash.Write(frameBody)
+
+	sum := hash.Sum(nil)
+	sign := make([]byte, hex.EncodedLen(len(sum)))
+	_ = hex.Encode(sign, sum)
+	return sign
+}
+
+// hmacSignature returns hmac-sha256(token, head+frameBody).
+func hmacSignature(token, head, frameBody []byte) []byte {
+	mac := hmac.New(sha256.New, token)
+	_, _ = mac.Write(head)
+	_, _ = mac.Write(frameBody)
+	return mac.Sum(nil)
 }
 
 // EncryptFrameHead 加密帧
@@ -172,6 +449,17 @@ func NewEncryptFrameHead() *EncryptFrameHead {
 	}
 }
 
+// NewGCMEncryptFrameHead create an encrypt frame head of EncryptVersionGCM,
+// the frame body is sealed by aes-gcm, with a random nonce carried in the frame,
+// and the frame head is authenticated as additional data.
+func NewGCMEncryptFrameHead() *EncryptFrameHead {
+	return &EncryptFrameHead{
+		FrameType:    FrameTypeEncrypt,
+		Version:      EncryptVersionGCM,
+		ProtocolType: ProtocolTypeRPC,
+	}
+}
+
 // Extract extracts field values of the FrameHead from the buffer.
 func (h *EncryptFrameHead) Extract(buf []byte) {
 	h.FrameType = buf[0]
@@ -185,30 +473,142 @@ func (h *EncryptFrameHead) Extract(buf []byte) {
 func (h *EncryptFrameHead) Construct(workspaceID int, token, frameBody []byte) ([]byte, error) {
 	h.WorkspaceID = uint32(workspaceID)
 
+	if h.Version == EncryptVersionGCM {
+		return h.sealGCM(nil, token, frameBody)
+	}
+
+	encryptFrameBody, err := h.encryptCBC(token, frameBody)
+	if err != nil {
+		return nil, err
+	}
+
+	// construct the buffer
+	buf := make([]byte, h.TotalLen)
+	h.encode(buf)
+
+	copy(buf[EncryptFrameHeadLen:], encryptFrameBody)
+	return buf, nil
+}
+
+// encryptCBC returns the aes-cbc encrypted frame body of EncryptVersion, and sets TotalLen.
+func (h *EncryptFrameHead) encryptCBC(token, frameBody []byte) ([]byte, error) {
 	encryptFrameBody, err := aesEncrypt(frameBody, token)
 	if err != nil {
 		return nil, err
 	}
 
-	h.TotalLen = EncryptFrameHeadLen + uint32(len(encryptFrameBody))
-	if h.TotalLen > uint32(MaxFrameSize) {
+	totalLen := int64(EncryptFrameHeadLen) + int64(len(encryptFrameBody))
+	if totalLen > int64(MaxFrameSize) {
 		return nil, ErrFrameTooLarge
 	}
 
-	if h.TotalLen > math.MaxUint32 {
+	if totalLen > math.MaxUint32 {
 		return nil, ErrHeadOverflowsUint32
 	}
 
+	h.TotalLen = uint32(totalLen)
+	return encryptFrameBody, nil
+}
+
+// sealGCM appends the whole frame of EncryptVersionGCM to dst[:0] and returns it,
+// which is frame head + nonce + aes-gcm sealed frame body.
+func (h *EncryptFrameHead) sealGCM(dst, token, frameBody []byte) ([]byte, error) {
+	aead, err := newGCM(token)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := aead.NonceSize()
+	totalLen := int64(EncryptFrameHeadLen) + int64(nonceSize) + int64(len(frameBody)) + int64(aead.Overhead())
+	if totalLen > int64(MaxFrameSize) {
+		return nil, ErrFrameTooLarge
+	}
+
+	if totalLen > math.MaxUint32 {
+		return nil, ErrHeadOverflowsUint32
+	}
+
+	h.TotalLen = uint32(totalLen)
+
 	// construct the buffer
-	buf := make([]byte, h.TotalLen)
+	if int64(cap(dst)) < totalLen {
+		dst = make([]byte, 0, totalLen)
+	}
+	buf := dst[:EncryptFrameHeadLen+nonceSize]
+	h.encode(buf)
+
+	nonce := buf[EncryptFrameHeadLen:]
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("AES-GCM generate nonce error: %v", err)
+	}
+
+	return aead.Seal(buf, nonce, frameBody, buf[:EncryptFrameHeadLen]), nil
+}
+
+// encode writes field values of the EncryptFrameHead into the buffer.
+func (h *EncryptFrameHead) encode(buf []byte) {
 	buf[0] = h.FrameType
 	buf[1] = h.Version
 	buf[2] = h.ProtocolType
 	binary.BigEndian.PutUint32(buf[3:7], h.TotalLen)
 	binary.BigEndian.PutUint32(buf[7:11], h.WorkspaceID)
+}
 
-	copy(buf[EncryptFrameHeadLen:], encryptFrameBody)
-	return buf, nil
+// Open decrypts the encrypted frame body with workspace token.
+func (h *EncryptFrameHead) Open(token, encryptFrameBody []byte) ([]byte, error) {
+	if int64(h.TotalLen) != int64(EncryptFrameHeadLen)+int64(len(encryptFrameBody)) {
+		return nil, ErrFrameLenMismatch
+	}
+
+	switch h.Version {
+	case EncryptVersion:
+		return aesDecrypt(encryptFrameBody, token)
+	case EncryptVersionGCM:
+		return h.openGCM(token, encryptFrameBody)
+	default:
+		return nil, ErrFrameVersion
+	}
+}
+
+// openGCM opens the nonce + aes-gcm sealed frame body of EncryptVersionGCM.
+func (h *EncryptFrameHead) openGCM(token, encryptFrameBody []byte) ([]byte, error) {
+	aead, err := newGCM(token)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := aead.NonceSize()
+	if len(encryptFrameBody) < nonceSize+aead.Overhead() {
+		return nil, ErrFrameBadCipher
+	}
+
+	head := make([]byte, EncryptFrameHeadLen)
+	h.encode(head)
+
+	frameBody, err := aead.Open(nil, encryptFrameBody[:nonceSize], encryptFrameBody[nonceSize:], head)
+	if err != nil {
+		return nil, ErrFrameAuthFailed
+	}
+
+	return frameBody, nil
+}
+
+// OpenEncryptFrame extracts the encrypt frame head from the whole frame and decrypts
+// its body, returns the encrypt frame head and the decrypted frame body.
+func OpenEncryptFrame(buf, token []byte) (*EncryptFrameHead, []byte, error) {
+	if len(buf) < EncryptFrameHeadLen {
+		return nil, nil, ErrFrameLenMismatch
+	}
+
+	h := &EncryptFrameHead{}
+	h.Extract(buf)
+
+	frameBody, err := h.Open(token, buf[EncryptFrameHeadLen:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return h, frameBody, nil
 }
 
 func aesEncrypt(orig, key []byte) (buf []byte, err error) {
@@ -234,9 +634,74 @@ func aesEncrypt(orig, key []byte) (buf []byte, err error) {
 	return buf, nil
 }
 
+func aesDecrypt(crypted, key []byte) (buf []byte, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("AES Decrypt Panic: %v", e)
+		}
+	}()
+
+	block, err := aes.NewCipher(key)
+	if block == nil {
+		return nil, fmt.Errorf("AES Decrypt NewCipher Error: %v", err)
+	}
+
+	orig := make([]byte, base64.StdEncoding.DecodedLen(len(crypted)))
+	n, err := base64.StdEncoding.Decode(orig, crypted)
+	if err != nil {
+		return nil, ErrFrameBadCipher
+	}
+	orig = orig[:n]
+
+	blockSize := block.BlockSize()
+	if len(orig) == 0 || len(orig)%blockSize != 0 {
+		return nil, ErrFrameBadCipher
+	}
+
+	blockMode := cipher.NewCBCDecrypter(block, key[:blockSize])
+	blockMode.CryptBlocks(orig, orig)
+	return pkcs7UnPadding(orig, blockSize)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("AES-GCM NewCipher Error: %v", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
 // 补码
 func pkcs7Padding(ciphertext []byte, blocksize int) []byte {
 	padding := blocksize - len(ciphertext)%blocksize
 	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
-	return append(ciphertext, padtext...)
+
+	// never append to ciphertext directly, which may overwrite the caller's buffer.
+	buf := make([]byte, 0, len(ciphertext)+padding)
+	buf = append(buf, ciphertext...)
+	return append(buf, padtext...)
+}
+
+// 去码
+func pkcs7UnPadding(origData []byte, blockSize int) ([]byte, error) {
+	length := len(origData)
+	if length == 0 {
+		return nil, ErrFrameBadPadding
+	}
+
+	padding := int(origData[length-1])
+	if padding == 0 || padding > blockSize || padding > length {
+		return nil, ErrFrameBadPadding
+	}
+
+	var bad byte
+	for _, b := range origData[length-padding:] {
+		bad |= b ^ byte(padding)
+	}
+	if bad != 0 {
+		return nil, ErrFrameBadPadding
+	}
+
+	return origData[:length-padding], nil
 }
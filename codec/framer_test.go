@@ -0,0 +1,323 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+var (
+	benchToken  = []byte("0123456789abcdef")
+	benchHeader = bytes.Repeat([]byte("h"), 128)
+	benchBody   = bytes.Repeat([]byte("b"), 32*1024)
+)
+
+func TestWriteFrame(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewFrameHead()
+	h.EnableChecksum()
+
+	expect, err := h.Construct(benchHeader, benchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = h.WriteFrame(&buf, benchHeader, benchBody); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), expect) {
+		t.Fatal("frame written by WriteFrame is different from Construct")
+	}
+
+	buf.Reset()
+	if _, err = NewHMACSignFrameHead().WriteFrame(&buf, 1, benchToken, benchBody); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = VerifySignFrame(buf.Bytes(), benchToken); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range []*EncryptFrameHead{NewEncryptFrameHead(), NewGCMEncryptFrameHead()} {
+		buf.Reset()
+		if _, err = h.WriteFrame(&buf, 1, benchToken, benchBody); err != nil {
+			t.Fatal(err)
+		}
+
+		_, frameBody, err := OpenEncryptFrame(buf.Bytes(), benchToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(frameBody, benchBody) {
+			t.Fatalf("frame body of encrypt version %d mismatch", h.Version)
+		}
+	}
+}
+
+func TestVerifySignFrame(t *testing.T) {
+	signed, err := NewSignFrameHead().Construct(1, benchToken, benchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmacSigned, err := NewHMACSignFrameHead().Construct(1, benchToken, benchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmacSignedEmpty, err := NewHMACSignFrameHead().Construct(1, benchToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		frame []byte
+		token []byte
+		err   error
+	}{
+		{"valid", signed, benchToken, nil},
+		{"wrong token", signed, []byte("fedcba9876543210"), ErrFrameBadSignature},
+		{"tampered body", modify(signed, func(buf []byte) { buf[len(buf)-1] ^= 1 }), benchToken, ErrFrameBadSignature},
+		{"tampered signature", modify(signed, func(buf []byte) { buf[11] ^= 1 }), benchToken, ErrFrameBadSignature},
+		{"length mismatch", modify(signed, func(buf []byte) {
+			binary.BigEndian.PutUint32(buf[3:7], uint32(len(buf)+1))
+		}), benchToken, ErrFrameLenMismatch},
+		{"truncated head", signed[:SignFrameHeadLen-1], benchToken, ErrFrameLenMismatch},
+		{"truncated body", signed[:len(signed)-1], benchToken, ErrFrameLenMismatch},
+		{"empty", nil, benchToken, ErrFrameLenMismatch},
+		{"unsupported version", modify(signed, func(buf []byte) { buf[1] = 0 }), benchToken, ErrFrameVersion},
+		{"hmac valid", hmacSigned, benchToken, nil},
+		{"hmac wrong token", hmacSigned, []byte("fedcba9876543210"), ErrFrameBadSignature},
+		{"hmac tampered body", modify(hmacSigned, func(buf []byte) { buf[len(buf)-1] ^= 1 }),
+			benchToken, ErrFrameBadSignature},
+		{"hmac tampered signature", modify(hmacSigned, func(buf []byte) { buf[HMACSignFrameHeadLen] ^= 1 }),
+			benchToken, ErrFrameBadSignature},
+		{"hmac tampered timestamp", modify(hmacSigned, func(buf []byte) { buf[18] ^= 1 }),
+			benchToken, ErrFrameBadSignature},
+		{"hmac head changed after signing", modify(hmacSigned, func(buf []byte) {
+			binary.BigEndian.PutUint32(buf[7:11], 2)
+		}), benchToken, ErrFrameBadSignature},
+		{"hmac within time window", hmacFrame(t, now.Add(-SignTimeWindow/2)), benchToken, nil},
+		{"hmac expired timestamp", hmacFrame(t, now.Add(-SignTimeWindow-time.Minute)), benchToken, ErrFrameExpired},
+		{"hmac future timestamp", hmacFrame(t, now.Add(SignTimeWindow+time.Minute)), benchToken, ErrFrameExpired},
+		{"hmac signature len changed", modify(hmacSigned, func(buf []byte) { buf[19] = sha256.Size / 2 }),
+			benchToken, ErrFrameBadSignature},
+		{"hmac signature len overflows frame", modify(hmacSignedEmpty, func(buf []byte) { buf[19] = 0xff }),
+			benchToken, ErrFrameLenMismatch},
+		{"hmac truncated head", hmacSigned[:HMACSignFrameHeadLen-1], benchToken, ErrFrameLenMismatch},
+		{"hmac truncated signature", hmacSigned[:HMACSignFrameHeadLen+1], benchToken, ErrFrameLenMismatch},
+		{"hmac truncated body", hmacSigned[:len(hmacSigned)-1], benchToken, ErrFrameLenMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, frameBody, err := VerifySignFrame(tt.frame, tt.token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("VerifySignFrame error = %v, want %v", err, tt.err)
+			}
+
+			if err == nil && (h.WorkSpaceID != 1 || !bytes.Equal(frameBody, benchBody)) {
+				t.Fatal("signature frame mismatch")
+			}
+		})
+	}
+}
+
+func TestOpenEncryptFrame(t *testing.T) {
+	encrypted, err := NewEncryptFrameHead().Construct(1, benchToken, benchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := NewGCMEncryptFrameHead().Construct(1, benchToken, benchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 12 bytes nonce + 16 bytes tag, without frame body.
+	sealedEmpty, err := NewGCMEncryptFrameHead().Construct(1, benchToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		frame []byte
+		token []byte
+		err   error
+	}{
+		{"valid", encrypted, benchToken, nil},
+		{"bad padding", cbcFrame(t, append(bytes.Repeat([]byte("b"), aes.BlockSize-1), 0)), benchToken, ErrFrameBadPadding},
+		{"padding larger than block", cbcFrame(t, append(bytes.Repeat([]byte("b"), aes.BlockSize-1), aes.BlockSize+1)),
+			benchToken, ErrFrameBadPadding},
+		{"inconsistent padding", cbcFrame(t, append(bytes.Repeat([]byte("b"), aes.BlockSize-2), 1, 2)),
+			benchToken, ErrFrameBadPadding},
+		{"cipher not base64", modify(encrypted, func(buf []byte) { buf[EncryptFrameHeadLen] = '*' }),
+			benchToken, ErrFrameBadCipher},
+		{"cipher not full blocks", encryptFrame([]byte("YmJi")), benchToken, ErrFrameBadCipher},
+		{"empty cipher", encryptFrame(nil), benchToken, ErrFrameBadCipher},
+		{"length mismatch", modify(encrypted, func(buf []byte) {
+			binary.BigEndian.PutUint32(buf[3:7], uint32(len(buf)-1))
+		}), benchToken, ErrFrameLenMismatch},
+		{"truncated head", encrypted[:EncryptFrameHeadLen-1], benchToken, ErrFrameLenMismatch},
+		{"truncated body", encrypted[:len(encrypted)-1], benchToken, ErrFrameLenMismatch},
+		{"unsupported version", modify(encrypted, func(buf []byte) { buf[1] = 0 }), benchToken, ErrFrameVersion},
+		{"gcm valid", sealed, benchToken, nil},
+		{"gcm wrong token", sealed, []byte("fedcba9876543210"), ErrFrameAuthFailed},
+		{"gcm tampered body", modify(sealed, func(buf []byte) { buf[len(buf)/2] ^= 1 }), benchToken, ErrFrameAuthFailed},
+		{"gcm tampered nonce", modify(sealed, func(buf []byte) { buf[EncryptFrameHeadLen] ^= 1 }),
+			benchToken, ErrFrameAuthFailed},
+		{"gcm tampered tag", modify(sealed, func(buf []byte) { buf[len(buf)-1] ^= 1 }), benchToken, ErrFrameAuthFailed},
+		{"gcm head changed after sealing", modify(sealed, func(buf []byte) {
+			binary.BigEndian.PutUint32(buf[7:11], 2)
+		}), benchToken, ErrFrameAuthFailed},
+		{"gcm protocol type changed after sealing", modify(sealed, func(buf []byte) { buf[2] = ProtocolTypeHTTP }),
+			benchToken, ErrFrameAuthFailed},
+		{"gcm version downgraded after sealing", modify(sealed, func(buf []byte) { buf[1] = EncryptVersion }),
+			benchToken, ErrFrameBadCipher},
+		{"gcm shorter than nonce and tag", modify(sealedEmpty[:len(sealedEmpty)-1], func(buf []byte) {
+			binary.BigEndian.PutUint32(buf[3:7], uint32(len(buf)))
+		}), benchToken, ErrFrameBadCipher},
+		{"gcm truncated body", sealed[:len(sealed)-1], benchToken, ErrFrameLenMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, frameBody, err := OpenEncryptFrame(tt.frame, tt.token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("OpenEncryptFrame error = %v, want %v", err, tt.err)
+			}
+
+			if err == nil && (h.WorkspaceID != 1 || !bytes.Equal(frameBody, benchBody)) {
+				t.Fatal("encrypt frame mismatch")
+			}
+		})
+	}
+}
+
+// modify returns a copy of frame modified by fn.
+func modify(frame []byte, fn func(buf []byte)) []byte {
+	buf := append([]byte{}, frame...)
+	fn(buf)
+	return buf
+}
+
+// hmacFrame returns a signature frame of SignVersionHMAC signed at the time.
+func hmacFrame(t *testing.T, signTime time.Time) []byte {
+	h := NewHMACSignFrameHead()
+	h.WorkSpaceID = 1
+	h.Timestamp = uint64(signTime.UnixMilli())
+	h.Sign = make([]byte, sha256.Size)
+	h.TotalLen = uint32(h.HeadLen() + len(benchBody))
+
+	buf := make([]byte, h.TotalLen)
+	h.encode(buf)
+	copy(buf[HMACSignFrameHeadLen:], hmacSignature(benchToken, buf[:HMACSignFrameHeadLen], benchBody))
+	copy(buf[h.HeadLen():], benchBody)
+
+	if _, _, err := VerifySignFrame(buf, benchToken); err != nil && err != ErrFrameExpired {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+// encryptFrame returns an encrypt frame of EncryptVersion with the raw encrypted frame body.
+func encryptFrame(encryptFrameBody []byte) []byte {
+	h := NewEncryptFrameHead()
+	h.WorkspaceID = 1
+	h.TotalLen = uint32(EncryptFrameHeadLen + len(encryptFrameBody))
+
+	buf := make([]byte, h.TotalLen)
+	h.encode(buf)
+	copy(buf[EncryptFrameHeadLen:], encryptFrameBody)
+	return buf
+}
+
+// cbcFrame returns an encrypt frame of EncryptVersion whose body is encrypted
+// from the full blocks of plain text without padding.
+func cbcFrame(t *testing.T, plain []byte) []byte {
+	block, err := aes.NewCipher(benchToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crypted := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, benchToken[:aes.BlockSize]).CryptBlocks(crypted, plain)
+	return encryptFrame([]byte(base64.StdEncoding.EncodeToString(crypted)))
+}
+
+func BenchmarkFrameHeadConstruct(b *testing.B) {
+	h := NewFrameHead()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ := h.Construct(benchHeader, benchBody)
+		_, _ = io.Discard.Write(buf)
+	}
+}
+
+func BenchmarkFrameHeadWriteFrame(b *testing.B) {
+	h := NewFrameHead()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = h.WriteFrame(io.Discard, benchHeader, benchBody)
+	}
+}
+
+func BenchmarkSignFrameHeadConstruct(b *testing.B) {
+	h := NewHMACSignFrameHead()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ := h.Construct(1, benchToken, benchBody)
+		_, _ = io.Discard.Write(buf)
+	}
+}
+
+func BenchmarkSignFrameHeadWriteFrame(b *testing.B) {
+	h := NewHMACSignFrameHead()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = h.WriteFrame(io.Discard, 1, benchToken, benchBody)
+	}
+}
+
+func BenchmarkEncryptFrameHeadConstruct(b *testing.B) {
+	h := NewGCMEncryptFrameHead()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ := h.Construct(1, benchToken, benchBody)
+		_, _ = io.Discard.Write(buf)
+	}
+}
+
+func BenchmarkEncryptFrameHeadWriteFrame(b *testing.B) {
+	h := NewGCMEncryptFrameHead()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = h.WriteFrame(io.Discard, 1, benchToken, benchBody)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/horm-database/common/consts"
+	"github.com/horm-database/common/errs"
+	"github.com/horm-database/common/proto"
+
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// ProtocolHorm is the name of horm rpc protocol.
+const ProtocolHorm = "horm"
+
+func init() {
+	RegisterCodec(ProtocolHorm, &HormServerCodec{}, &HormClientCodec{})
+}
+
+// HormServerCodec is the server codec of horm rpc protocol, the frame is
+// FrameHead + proto.RequestHeader/proto.ResponseHeader + body.
+type HormServerCodec struct{}
+
+// Decode unpacks the request frame, fills msg by the request header, and returns the request body.
+func (c *HormServerCodec) Decode(msg *Msg, frame []byte) ([]byte, error) {
+	if msg.ReceiveTime().IsZero() { // may be set earlier by transport when the frame is read.
+		msg.WithReceiveTime(time.Now())
+	}
+
+	header, body, err := unpackFrame(frame)
+	if err != nil {
+		return nil, errs.Newf(errs.ErrServerReadFrame, "server decode frame error: %v", err)
+	}
+
+	req := &proto.RequestHeader{}
+	if err = protobuf.Unmarshal(header, req); err != nil {
+		return nil, errs.Newf(errs.ErrServerDecode, "server decode request header error: %v", err)
+	}
+
+	md, err := req.MetaData()
+	if err != nil {
+		return nil, errs.Newf(errs.ErrServerDecode, "server decode request metadata error: %v", err)
+	}
+
+	msg.WithServerReqHead(req)
+	msg.WithMetaData(md)
+	msg.WithRequestID(req.GetRequestId())
+	msg.WithTraceID(req.GetTraceId())
+	msg.WithRequestTimeout(time.Duration(req.GetTimeout()) * time.Millisecond)
+	msg.WithCallerServiceName(req.GetCaller())
+	msg.WithCallRPCName(req.GetCallee())
+	msg.WithCompressType(req.GetCompress())
+	msg.WithCompressDict(req.GetCompressDict())
+
+	return body, nil
+}
+
+// Encode packs the response body, the response header is built from msg, such as request id
+// and server response error. The response head set by handler in msg is used if any.
+func (c *HormServerCodec) Encode(msg *Msg, body []byte) ([]byte, error) {
+	rsp, ok := msg.ServerRespHead().(*proto.ResponseHeader)
+	if !ok {
+		rsp = &proto.ResponseHeader{}
+		msg.WithServerRespHead(rsp)
+	}
+
+	if req, ok := msg.ServerReqHead().(*proto.RequestHeader); ok {
+		rsp.Version = req.GetVersion()
+		rsp.QueryMode = req.GetQueryMode()
+	}
+
+	rsp.RequestId = msg.RequestID()
+	rsp.Compress = msg.CompressType() // answer with the compress type and dictionary of request.
+	rsp.CompressDict = msg.CompressDict()
+	if msg.RespUncompressed() {
+		rsp.Compress, rsp.CompressDict = consts.CompressTypeNone, 0
+	}
+
+	if e := msg.ServerRespError(); e != nil {
+		rsp.Err = &proto.Error{
+			Type: int32(e.Type),
+			Code: int32(e.Code),
+			Msg:  e.Msg,
+			Sql:  e.Sql,
+		}
+	}
+
+	header, err := protobuf.Marshal(rsp)
+	if err != nil {
+		return nil, errs.Newf(errs.ErrServerEncode, "server encode response header error: %v", err)
+	}
+
+	frame, err := NewFrameHead().Construct(header, body)
+	if err != nil {
+		return nil, errs.Newf(errs.ErrServerEncode, "server encode frame error: %v", err)
+	}
+
+	return frame, nil
+}
+
+// HormClientCodec is the client codec of horm rpc protocol.
+type HormClientCodec struct{}
+
+// Encode packs the request body, the request header is built from msg, such as request id,
+// trace id, timeout, caller and callee. The request head set in msg is used if any.
+func (c *HormClientCodec) Encode(msg *Msg, body []byte) ([]byte, error) {
+	req, ok := msg.ClientReqHead().(*proto.RequestHeader)
+	if !ok {
+		req = &proto.RequestHeader{}
+		msg.WithClientReqHead(req)
+	}
+
+	req.RequestId = msg.RequestID()
+	req.TraceId = msg.TraceID()
+	req.Timeout = uint32(msg.RequestTimeout() / time.Millisecond)
+	req.Caller = msg.CallerServiceName()
+	req.Callee = msg.CallRPCName()
+	req.Compress = msg.CompressType()
+	req.CompressDict = msg.CompressDict()
+	if req.Timestamp == 0 {
+		req.Timestamp = uint64(time.Now().UnixMilli())
+	}
+
+	if len(msg.MetaData()) > 0 {
+		if err := req.SetMetaData(msg.MetaData()); err != nil {
+			return nil, errs.Newf(errs.ErrClientEncode, "client encode request metadata error: %v", err)
+		}
+	}
+
+	header, err := protobuf.Marshal(req)
+	if err != nil {
+		return nil, errs.Newf(errs.ErrClientEncode, "client encode request header error: %v", err)
+	}
+
+	frame, err := NewFrameHead().Construct(header, body)
+	if err != nil {
+		return nil, errs.Newf(errs.ErrClientEncode, "client encode frame error: %v", err)
+	}
+
+	return frame, nil
+}
+
+// Decode unpacks the response frame, sets client response head and error of msg,
+// and returns the response body.
+func (c *HormClientCodec) Decode(msg *Msg, frame []byte) ([]byte, error) {
+	header, body, err := unpackFrame(frame)
+	if err != nil {
+		return nil, errs.Newf(errs.ErrClientReadFrame, "client decode frame error: %v", err)
+	}
+
+	rsp := &proto.ResponseHeader{}
+	if err = protobuf.Unmarshal(header, rsp); err != nil {
+		return nil, errs.Newf(errs.ErrClientDecode, "client decode response header error: %v", err)
+	}
+
+	msg.WithClientRespHead(rsp)
+	msg.WithCompressType(rsp.GetCompress())
+	msg.WithCompressDict(rsp.GetCompressDict())
+
+	if rsp.GetRequestId() != msg.RequestID() {
+		return nil, errs.Newf(errs.ErrRequestIDNotMatch,
+			"response request id %d not match request id %d", rsp.GetRequestId(), msg.RequestID())
+	}
+
+	if rsp.GetErr() != nil && rsp.GetErr().GetCode() != errs.Success {
+		msg.WithClientRespError(rsp.GetErr().ToError())
+	}
+
+	return body, nil
+}
+
+// unpackFrame returns header and body of the whole normal frame.
+func unpackFrame(frame []byte) (header, body []byte, err error) {
+	if len(frame) < FrameHeadLen {
+		return nil, nil, ErrFrameTotalLen
+	}
+
+	headLen := FrameHeadLen + frameHeadExtLen(binary.BigEndian.Uint16(frame[8:10]))
+	if len(frame) < headLen {
+		return nil, nil, ErrFrameTotalLen
+	}
+
+	head := FrameHead{}
+	head.Extract(frame)
+	if head.FrameType != FrameTypeNormal {
+		return nil, nil, ErrFrameType
+	}
+
+	if int(head.TotalLen) != len(frame) {
+		return nil, nil, ErrFrameLenMismatch
+	}
+
+	if int(head.HeaderLen) > len(frame)-headLen {
+		return nil, nil, ErrFrameHeaderLen
+	}
+
+	header = frame[headLen : headLen+int(head.HeaderLen)]
+	body = frame[headLen+int(head.HeaderLen):]
+	if err = head.Verify(header, body); err != nil {
+		return nil, nil, err
+	}
+
+	return header, body, nil
+}
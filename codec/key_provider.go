@@ -0,0 +1,319 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"encoding/binary"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/horm-database/common/errs"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrKeyNotFound = errs.New(errs.ErrAuthFail, "secret key of workspace not found")
+)
+
+// Key is the secret key of workspace, used as token of signature and encrypt frames.
+type Key struct {
+	Version uint32 // key version, the larger the newer.
+	Secret  []byte // secret key
+}
+
+// KeyProvider provides the secret keys of workspace. During key rotation, a workspace
+// may have multiple active keys, frames are constructed with the current key, and
+// verified or opened with the current key and then the previous keys.
+//
+// Frames carry no key version, so the keys are tried in order rather than looked up by Key.
+// Encrypt frames of EncryptVersion (aes-cbc) can not rotate keys, they are opened with the
+// current key only, since a wrong key can not be detected without mac. The clients of
+// EncryptVersion must switch to the new key at the same time as the server, or upgrade to
+// EncryptVersionGCM before key rotation.
+type KeyProvider interface {
+	// Key returns the key of workspace with the specified version, which is for the caller
+	// that knows the key version by other means, such as the configuration of client.
+	Key(workspaceID, version uint32) (*Key, error)
+
+	// ActiveKeys returns all active keys of workspace, the current (newest) key first.
+	ActiveKeys(workspaceID uint32) ([]*Key, error)
+}
+
+// MemKeyProvider is a goroutine-safe in-memory KeyProvider.
+type MemKeyProvider struct {
+	mu   sync.RWMutex
+	keys map[uint32][]*Key // workspace id => keys sorted by version desc
+}
+
+// NewMemKeyProvider create an empty in-memory KeyProvider.
+func NewMemKeyProvider() *MemKeyProvider {
+	return &MemKeyProvider{keys: map[uint32][]*Key{}}
+}
+
+// Key implements KeyProvider.
+func (p *MemKeyProvider) Key(workspaceID, version uint32) (*Key, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, key := range p.keys[workspaceID] {
+		if key.Version == version {
+			return key, nil
+		}
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+// ActiveKeys implements KeyProvider.
+func (p *MemKeyProvider) ActiveKeys(workspaceID uint32) ([]*Key, error) {
+	p.mu.RLock()
+	keys := append([]*Key(nil), p.keys[workspaceID]...)
+	p.mu.RUnlock()
+
+	if len(keys) == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	return keys, nil // copied, so that the caller can not modify the keys of provider.
+}
+
+// AddKey adds a key to workspace, the key with same version will be replaced.
+func (p *MemKeyProvider) AddKey(workspaceID uint32, key *Key) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys := make([]*Key, 0, len(p.keys[workspaceID])+1)
+	for _, k := range p.keys[workspaceID] {
+		if k.Version != key.Version {
+			keys = append(keys, k)
+		}
+	}
+
+	p.keys[workspaceID] = sortKeys(append(keys, key))
+}
+
+// RemoveKey retires the key of workspace with the specified version.
+func (p *MemKeyProvider) RemoveKey(workspaceID, version uint32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys := make([]*Key, 0, len(p.keys[workspaceID]))
+	for _, k := range p.keys[workspaceID] {
+		if k.Version != version {
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		delete(p.keys, workspaceID)
+	} else {
+		p.keys[workspaceID] = keys
+	}
+}
+
+// SetKeys replaces all keys of all workspaces.
+func (p *MemKeyProvider) SetKeys(keys map[uint32][]*Key) {
+	m := make(map[uint32][]*Key, len(keys))
+	for workspaceID, wsKeys := range keys {
+		if len(wsKeys) > 0 {
+			m[workspaceID] = sortKeys(append([]*Key{}, wsKeys...))
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = m
+	p.mu.Unlock()
+}
+
+// FileKeyProvider is a KeyProvider that loads keys from yaml file, such as:
+//
+//	1:                    # workspace id
+//	  - version: 2        # current key
+//	    secret: "0123456789abcdef"
+//	  - version: 1        # previous key
+//	    secret: "fedcba9876543210"
+//
+// Call Reload after the file is modified during key rotation.
+type FileKeyProvider struct {
+	*MemKeyProvider
+	path string
+}
+
+// fileKey is the key config in file.
+type fileKey struct {
+	Version uint32 `yaml:"version"` // key version
+	Secret  string `yaml:"secret"`  // secret key
+}
+
+// NewFileKeyProvider create a KeyProvider which loads keys from the yaml file.
+func NewFileKeyProvider(path string) (*FileKeyProvider, error) {
+	p := &FileKeyProvider{
+		MemKeyProvider: NewMemKeyProvider(),
+		path:           path,
+	}
+
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Reload reloads all keys from the file.
+func (p *FileKeyProvider) Reload() error {
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+
+	fileKeys := map[uint32][]*fileKey{}
+	if err = yaml.Unmarshal(content, &fileKeys); err != nil {
+		return err
+	}
+
+	keys := make(map[uint32][]*Key, len(fileKeys))
+	for workspaceID, wsKeys := range fileKeys {
+		for _, k := range wsKeys {
+			keys[workspaceID] = append(keys[workspaceID], &Key{Version: k.Version, Secret: []byte(k.Secret)})
+		}
+	}
+
+	p.SetKeys(keys)
+	return nil
+}
+
+// ConstructWithKeys constructs the whole frame with current key of workspace.
+func (h *SignFrameHead) ConstructWithKeys(workspaceID int, kp KeyProvider, frameBody []byte) ([]byte, error) {
+	keys, err := kp.ActiveKeys(uint32(workspaceID))
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Construct(workspaceID, keys[0].Secret, frameBody)
+}
+
+// VerifyWithKeys verifies the signature of the frame body with the current key of workspace,
+// and then the previous keys.
+func (h *SignFrameHead) VerifyWithKeys(kp KeyProvider, frameBody []byte) error {
+	keys, err := kp.ActiveKeys(h.WorkSpaceID)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		err = h.Verify(key.Secret, frameBody)
+		if err != ErrFrameBadSignature {
+			return err
+		}
+	}
+
+	return err
+}
+
+// VerifySignFrameWithKeys is same as VerifySignFrame, but verifies with keys of workspace.
+func VerifySignFrameWithKeys(buf []byte, kp KeyProvider) (*SignFrameHead, []byte, error) {
+	if len(buf) < SignFrameHeadLen {
+		return nil, nil, ErrFrameLenMismatch
+	}
+
+	keys, err := kp.ActiveKeys(binary.BigEndian.Uint32(buf[7:11]))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, key := range keys {
+		h, frameBody, err := VerifySignFrame(buf, key.Secret)
+		if err != ErrFrameBadSignature {
+			return h, frameBody, err
+		}
+	}
+
+	return nil, nil, ErrFrameBadSignature
+}
+
+// ConstructWithKeys constructs the whole frame with current key of workspace.
+func (h *EncryptFrameHead) ConstructWithKeys(workspaceID int, kp KeyProvider, frameBody []byte) ([]byte, error) {
+	keys, err := kp.ActiveKeys(uint32(workspaceID))
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Construct(workspaceID, keys[0].Secret, frameBody)
+}
+
+// OpenWithKeys decrypts the encrypted frame body with the current key of workspace. Only frames
+// of EncryptVersionGCM fall back to the previous keys, because a wrong key is reliably detected
+// by the authentication tag, while aes-cbc of EncryptVersion has no mac and a wrong key still
+// passes the padding check by chance, which would return garbage as a successful decryption.
+// So frames of EncryptVersion can not rotate keys, see KeyProvider.
+func (h *EncryptFrameHead) OpenWithKeys(kp KeyProvider, encryptFrameBody []byte) ([]byte, error) {
+	keys, err := kp.ActiveKeys(h.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.Version != EncryptVersionGCM {
+		return h.Open(keys[0].Secret, encryptFrameBody)
+	}
+
+	var frameBody []byte
+	for _, key := range keys {
+		frameBody, err = h.Open(key.Secret, encryptFrameBody)
+		if err != ErrFrameAuthFailed {
+			return frameBody, err
+		}
+	}
+
+	return nil, err
+}
+
+// OpenEncryptFrameWithKeys is same as OpenEncryptFrame, but decrypts with keys of workspace.
+func OpenEncryptFrameWithKeys(buf []byte, kp KeyProvider) (*EncryptFrameHead, []byte, error) {
+	if len(buf) < EncryptFrameHeadLen {
+		return nil, nil, ErrFrameLenMismatch
+	}
+
+	h := &EncryptFrameHead{}
+	h.Extract(buf)
+
+	frameBody, err := h.OpenWithKeys(kp, buf[EncryptFrameHeadLen:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return h, frameBody, nil
+}
+
+// OpenWithKeys is same as Open, but verifies or decrypts with keys of workspace.
+func (f *Frame) OpenWithKeys(kp KeyProvider) ([]byte, error) {
+	switch f.FrameType {
+	case FrameTypeSignature:
+		if err := f.SignHead.VerifyWithKeys(kp, f.Body); err != nil {
+			return nil, err
+		}
+		return f.Body, nil
+	case FrameTypeEncrypt:
+		return f.EncryptHead.OpenWithKeys(kp, f.Body)
+	default:
+		return f.buf, nil
+	}
+}
+
+func sortKeys(keys []*Key) []*Key {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Version > keys[j].Version
+	})
+	return keys
+}
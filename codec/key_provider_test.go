@@ -0,0 +1,160 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := &Key{Version: 1, Secret: []byte("fedcba9876543210")}
+	newKey := &Key{Version: 2, Secret: benchToken}
+
+	kp := NewMemKeyProvider()
+	kp.AddKey(1, oldKey)
+
+	construct := func(t *testing.T, frameType, version uint8) []byte {
+		var frame []byte
+		var err error
+		switch frameType {
+		case FrameTypeSignature:
+			h := NewSignFrameHead()
+			h.Version = version
+			frame, err = h.ConstructWithKeys(1, kp, benchBody)
+		default:
+			h := NewEncryptFrameHead()
+			h.Version = version
+			frame, err = h.ConstructWithKeys(1, kp, benchBody)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return frame
+	}
+
+	frames := map[string][]byte{
+		"sign":        construct(t, FrameTypeSignature, SignVersion),
+		"hmac":        construct(t, FrameTypeSignature, SignVersionHMAC),
+		"cbc":         construct(t, FrameTypeEncrypt, EncryptVersion),
+		"gcm":         construct(t, FrameTypeEncrypt, EncryptVersionGCM),
+		"current":     nil,
+		"cbc current": nil,
+	}
+
+	// rotate to the new key, frames constructed with the old key are still accepted.
+	kp.AddKey(1, newKey)
+	frames["current"] = construct(t, FrameTypeEncrypt, EncryptVersionGCM)
+	frames["cbc current"] = construct(t, FrameTypeEncrypt, EncryptVersion)
+
+	open := func(frame []byte) ([]byte, error) {
+		if frame[0] == FrameTypeSignature {
+			_, frameBody, err := VerifySignFrameWithKeys(frame, kp)
+			return frameBody, err
+		}
+		_, frameBody, err := OpenEncryptFrameWithKeys(frame, kp)
+		return frameBody, err
+	}
+
+	tests := []struct {
+		name    string
+		frame   string
+		retire  bool // retire the old key before opening
+		success bool
+		err     error
+	}{
+		{"sign with previous key", "sign", false, true, nil},
+		{"hmac with previous key", "hmac", false, true, nil},
+		{"gcm with previous key", "gcm", false, true, nil},
+		{"gcm with current key", "current", false, true, nil},
+		// aes-cbc has no mac to detect a wrong key, so it can not rotate keys, and never falls back
+		// to the previous keys, the frame is rejected or decrypted into garbage.
+		{"cbc with previous key", "cbc", false, false, nil},
+		{"cbc with current key", "cbc current", false, true, nil},
+		{"sign with retired key", "sign", true, false, ErrFrameBadSignature},
+		{"hmac with retired key", "hmac", true, false, ErrFrameBadSignature},
+		{"gcm with retired key", "gcm", true, false, ErrFrameAuthFailed},
+		{"gcm with current key after retiring", "current", true, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kp.AddKey(1, oldKey)
+			if tt.retire {
+				kp.RemoveKey(1, oldKey.Version)
+			}
+
+			frameBody, err := open(frames[tt.frame])
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+
+			if success := err == nil && bytes.Equal(frameBody, benchBody); success != tt.success {
+				t.Fatalf("success = %v, want %v, error = %v", success, tt.success, err)
+			}
+		})
+	}
+
+	kp.RemoveKey(1, oldKey.Version)
+	kp.RemoveKey(1, newKey.Version)
+	if _, err := open(frames["gcm"]); err != ErrKeyNotFound {
+		t.Fatalf("error = %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestFileKeyProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	content := "1:\n  - version: 1\n    secret: \"fedcba9876543210\"\n  - version: 2\n    secret: \"0123456789abcdef\"\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	kp, err := NewFileKeyProvider(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := kp.ActiveKeys(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2 || keys[0].Version != 2 || !bytes.Equal(keys[0].Secret, benchToken) {
+		t.Fatal("the current key should be the first active key")
+	}
+
+	if _, err = kp.Key(1, 3); err != ErrKeyNotFound {
+		t.Fatalf("error = %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestMemKeyProviderActiveKeys(t *testing.T) {
+	kp := NewMemKeyProvider()
+	kp.AddKey(1, &Key{Version: 1, Secret: []byte("fedcba9876543210")})
+	kp.AddKey(1, &Key{Version: 2, Secret: benchToken})
+
+	keys, err := kp.ActiveKeys(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys[0] = &Key{Version: 3, Secret: []byte("modified")}
+
+	if keys, _ = kp.ActiveKeys(1); keys[0].Version != 2 {
+		t.Fatal("keys of provider are modified by the caller of ActiveKeys")
+	}
+}
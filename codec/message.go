@@ -16,9 +16,14 @@ package codec
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
 	"net"
+	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/horm-database/common/errs"
@@ -30,7 +35,11 @@ type Msg struct {
 	context           context.Context
 	frameCodec        interface{}
 	requestTimeout    time.Duration
+	receiveTime       time.Time // the time from which request timeout counts.
 	serializationType int
+	compressType      uint32
+	compressDict      uint32
+	respUncompressed  bool // response body is left uncompressed regardless of compressType.
 	callerServiceName string
 	callerMethod      string
 	calleeServiceName string
@@ -47,36 +56,126 @@ type Msg struct {
 	logger            logger.Logger
 	logSeq            int
 	env               string
+	metadata          map[string]string
 	requestID         uint64
 	spanID            uint64
 	traceID           string
+	parentSpanID      uint64
+	traceFlags        uint8
+	traceState        string
+	parent            *Msg   // the server msg from which the client msg is derived.
+	generation        uint64 // increased each time the msg is recycled.
+	poisoned          uint32 // 1 if the msg has been recycled in debug mode.
 }
 
+// ContextMsg is the context key of message.
+//
+// Deprecated: message is no longer stored under this key, use NewMessage and Message instead.
+// Message still reads the message put into ctx under this key for compatibility, which will
+// be removed in the next release.
 const ContextMsg = "CTX_MSG"
 
+// msgKey is the context key of message, which is unexported to avoid collisions.
+type msgKey struct{}
+
 // NewMessage create an empty message, and put it into ctx,
 func NewMessage(ctx context.Context) (context.Context, *Msg) {
 	m := msgPool.Get().(*Msg)
-	ctx = context.WithValue(ctx, ContextMsg, m)
+	ctx = context.WithValue(ctx, msgKey{}, m)
 	m.context = ctx
 	return ctx, m
 }
 
+// NewClientMessage derives the client message for a call to downstream from the message of ctx,
+// and put it into ctx. Each call should derive its own client message, so that the concurrent
+// calls of a handler have isolated client heads and errors, rather than overwriting the ones of
+// the server message. The client message keeps the trace, metadata, logger and other request
+// scoped information of the parent, its caller is the callee of parent, while the callee, server
+// and client heads and errors are left empty. The parent is returned by Parent.
+//
+// The call is a child span of the parent, the client message has a new span id, whose parent span id
+// is the span id of parent. The traceparent and tracestate metadata inherited from parent, which are
+// those of upstream, are dropped and then injected by ClientTraceInjector if it is set.
+//
+// The request timeout is copied from parent, ApplyBudget should be used to set the remaining
+// budget. The client message should be recycled by RecycleMessage after the call.
+func NewClientMessage(ctx context.Context) (context.Context, *Msg) {
+	parent := Message(ctx)
+
+	ctx, m := NewMessage(ctx)
+	CopyMsg(m, parent)
+
+	m.callerServiceName = parent.CalleeServiceName()
+	m.callerMethod = parent.CalleeMethod()
+	m.calleeServiceName = ""
+	m.calleeMethod = ""
+	m.callRPCName = ""
+	m.serverReqHead = nil
+	m.serverRespHead = nil
+	m.serverRespError = nil
+	m.respUncompressed = false
+	m.clientReqHead = nil
+	m.clientRespHead = nil
+	m.clientRespError = nil
+	m.parent = parent
+
+	if m.traceID != "" {
+		m.parentSpanID = parent.SpanID()
+		m.spanID = newSpanID()
+	}
+
+	delete(m.metadata, metaTraceParent)
+	delete(m.metadata, metaTraceState)
+	if ClientTraceInjector != nil {
+		ClientTraceInjector(m)
+	}
+
+	return ctx, m
+}
+
+const ( // metadata keys of W3C trace context, the same as trace.HeaderTraceParent and trace.HeaderTraceState.
+	metaTraceParent = "traceparent"
+	metaTraceState  = "tracestate"
+)
+
+// ClientTraceInjector injects the trace context of the client message derived by NewClientMessage
+// into its metadata, so that it is propagated to downstream. It is set by package trace.
+var ClientTraceInjector func(msg *Msg)
+
+// newSpanID generates a random non-zero span id.
+func newSpanID() uint64 {
+	var b [8]byte
+	for {
+		_, _ = rand.Read(b[:])
+		if id := binary.BigEndian.Uint64(b[:]); id != 0 {
+			return id
+		}
+	}
+}
+
 // Message returns the message of context.
 func Message(ctx context.Context) *Msg {
-	val := ctx.Value(ContextMsg)
-	m, ok := val.(*Msg)
-	if !ok {
-		return &Msg{context: ctx}
+	if m, ok := ctx.Value(msgKey{}).(*Msg); ok {
+		return m
 	}
-	return m
+
+	// fall back to the deprecated key, for message put into ctx by code of older version.
+	if m, ok := ctx.Value(ContextMsg).(*Msg); ok {
+		return m
+	}
+
+	return &Msg{context: ctx}
 }
 
+// NewAsyncMessage create a message copied from the message of ctx for asynchronous processing, whose
+// context times out after duration. The request timeout of upstream is not inherited, so that the
+// asynchronous processing is not limited by the budget of the original request.
 func NewAsyncMessage(ctx context.Context, duration time.Duration) (context.Context, context.CancelFunc, *Msg) {
 	tCtx, tCancel := context.WithTimeout(context.Background(), duration)
 
 	asyncCtx, asyncMsg := NewMessage(tCtx)
 	CopyMsg(asyncMsg, Message(ctx))
+	asyncMsg.detachBudget()
 
 	return asyncCtx, tCancel, asyncMsg
 }
@@ -90,7 +189,8 @@ func NewAsyncMessage(ctx context.Context, duration time.Duration) (context.Conte
 //
 // When the handler function runs asynchronously,
 // this method needs to be called before starting goroutine to copy the context,
-// leaving the original timeout control, and retains the information in Msg for Metrics.
+// leaving the original timeout control, and retains the information in Msg for Metrics. The request
+// timeout of upstream is not inherited either.
 //
 // Retain the log context for printing the associated log,
 // keep other value in context, such as tracing context, etc.
@@ -98,26 +198,88 @@ func CloneContext(ctx context.Context) context.Context {
 	oldMsg := Message(ctx)
 	newCtx, newMsg := NewMessage(detach(ctx))
 	CopyMsg(newMsg, oldMsg)
+	newMsg.detachBudget()
 	return newCtx
 }
 
+// detachBudget clears the request timeout and receive time of the message copied for asynchronous
+// processing, which would otherwise be exhausted by RemainingBudget once the original request ends.
+func (m *Msg) detachBudget() {
+	m.checkRecycled()
+	m.requestTimeout = 0
+	m.receiveTime = time.Time{}
+}
+
 var msgPool = sync.Pool{
 	New: func() interface{} {
 		return &Msg{}
 	},
 }
 
-// RecycleMessage reset message, then put it to pool.
+const ( // debug mode of use-after-recycle detection of Msg.
+	MsgDebugOff   = 0 // recycled msg is put back into pool, default.
+	MsgDebugLog   = 1 // recycled msg is poisoned and never reused, access to it is logged with stack.
+	MsgDebugPanic = 2 // recycled msg is poisoned and never reused, access to it panics.
+)
+
+var (
+	msgDebugMode int32
+
+	ErrMsgRecycled = errors.New("access to recycled msg")
+)
+
+// SetMsgDebugMode sets the debug mode of use-after-recycle detection, which is used to find the
+// goroutine still holding the msg after it has been recycled, such as log calls after the handler
+// returns. Messages recycled in debug mode are not put back into pool, so it should not be enabled
+// in production.
+func SetMsgDebugMode(mode int32) {
+	atomic.StoreInt32(&msgDebugMode, mode)
+}
+
+// RecycleMessage reset message, then put it to pool. The generation of message is increased,
+// and it is poisoned instead of being put back into pool in debug mode.
 func RecycleMessage(msg *Msg) {
 	msg.reset()
+	atomic.AddUint64(&msg.generation, 1)
+
+	if atomic.LoadInt32(&msgDebugMode) != MsgDebugOff {
+		atomic.StoreUint32(&msg.poisoned, 1)
+		return
+	}
+
 	msgPool.Put(msg)
 }
 
+// Generation returns the generation of message, which is increased each time the message is
+// recycled. The holder of message can keep the generation, and compare it before accessing the
+// message later, to detect whether the message has been recycled and reused by another request.
+func (m *Msg) Generation() uint64 {
+	return atomic.LoadUint64(&m.generation)
+}
+
+// checkRecycled logs or panics on access to the recycled message in debug mode.
+func (m *Msg) checkRecycled() {
+	mode := atomic.LoadInt32(&msgDebugMode)
+	if mode == MsgDebugOff || atomic.LoadUint32(&m.poisoned) == 0 {
+		return
+	}
+
+	if mode == MsgDebugPanic {
+		panic(ErrMsgRecycled)
+	}
+
+	logger.DefaultLogger.Error(ErrMsgRecycled.Error(), logger.Field{Key: "stack", Value: string(debug.Stack())})
+}
+
 func (m *Msg) reset() {
 	m.context = nil
 	m.frameCodec = nil
 	m.requestTimeout = 0
+	m.receiveTime = time.Time{}
 	m.serializationType = 0
+	m.compressType = 0
+	m.compressDict = 0
+	m.respUncompressed = false
 	m.callerServiceName = ""
 	m.callerMethod = ""
 	m.calleeServiceName = ""
@@ -132,123 +294,232 @@ func (m *Msg) reset() {
 	m.localAddr = nil
 	m.remoteAddr = nil
 	m.logger = nil
+	m.logSeq = 0
 	m.env = ""
+	m.metadata = nil
 	m.requestID = 0
-	m.logSeq = 0
+	m.spanID = 0
+	m.traceID = ""
+	m.parentSpanID = 0
+	m.traceFlags = 0
+	m.traceState = ""
+	m.parent = nil
 }
 
 // Context return context in message
 func (m *Msg) Context() context.Context {
+	m.checkRecycled()
 	return m.context
 }
 
 // Env returns environment.
 func (m *Msg) Env() string {
+	m.checkRecycled()
 	return m.env
 }
 
 // WithEnv sets environment.
 func (m *Msg) WithEnv(env string) {
+	m.checkRecycled()
 	m.env = env
 }
 
+// MetaData returns the transparent metadata, such as tenant, canary tag and user id,
+// which is propagated to downstream end to end.
+func (m *Msg) MetaData() map[string]string {
+	m.checkRecycled()
+	return m.metadata
+}
+
+// WithMetaData sets the transparent metadata.
+func (m *Msg) WithMetaData(md map[string]string) {
+	m.checkRecycled()
+	m.metadata = md
+}
+
+// MetaValue returns the value of key in metadata.
+func (m *Msg) MetaValue(key string) string {
+	m.checkRecycled()
+	return m.metadata[key]
+}
+
+// WithMetaValue sets the value of key in metadata.
+func (m *Msg) WithMetaValue(key, value string) {
+	m.checkRecycled()
+	if m.metadata == nil {
+		m.metadata = map[string]string{}
+	}
+	m.metadata[key] = value
+}
+
 // RemoteAddr returns remote address.
 func (m *Msg) RemoteAddr() net.Addr {
+	m.checkRecycled()
 	return m.remoteAddr
 }
 
 // WithRemoteAddr sets remote address.
 func (m *Msg) WithRemoteAddr(addr net.Addr) {
+	m.checkRecycled()
 	m.remoteAddr = addr
 }
 
 // LocalAddr returns local address.
 func (m *Msg) LocalAddr() net.Addr {
+	m.checkRecycled()
 	return m.localAddr
 }
 
 // WithLocalAddr set local address.
 func (m *Msg) WithLocalAddr(addr net.Addr) {
+	m.checkRecycled()
 	m.localAddr = addr
 }
 
 // RequestTimeout returns request timeout set by upstream business protocol.
 func (m *Msg) RequestTimeout() time.Duration {
+	m.checkRecycled()
 	return m.requestTimeout
 }
 
 // WithRequestTimeout sets request timeout.
 func (m *Msg) WithRequestTimeout(t time.Duration) {
+	m.checkRecycled()
 	m.requestTimeout = t
 }
 
+// ReceiveTime returns the time when the request was received, the request timeout counts from it,
+// the remaining time of request is RequestTimeout minus the time elapsed since ReceiveTime.
+func (m *Msg) ReceiveTime() time.Time {
+	m.checkRecycled()
+	return m.receiveTime
+}
+
+// WithReceiveTime sets the time when the request was received.
+func (m *Msg) WithReceiveTime(t time.Time) {
+	m.checkRecycled()
+	m.receiveTime = t
+}
+
 // FrameCodec returns frame codec.
 func (m *Msg) FrameCodec() interface{} {
+	m.checkRecycled()
 	return m.frameCodec
 }
 
 // WithFrameCodec sets frame codec.
 func (m *Msg) WithFrameCodec(f interface{}) {
+	m.checkRecycled()
 	m.frameCodec = f
 }
 
 // SerializationType returns the value of body serialization.
 func (m *Msg) SerializationType() int {
+	m.checkRecycled()
 	return m.serializationType
 }
 
 // WithSerializationType sets body serialization type of body.
 func (m *Msg) WithSerializationType(t int) {
+	m.checkRecycled()
 	m.serializationType = t
 }
 
+// CompressType returns the compress type of body.
+func (m *Msg) CompressType() uint32 {
+	m.checkRecycled()
+	return m.compressType
+}
+
+// WithCompressType sets the compress type of body.
+func (m *Msg) WithCompressType(t uint32) {
+	m.checkRecycled()
+	m.compressType = t
+}
+
+// CompressDict returns the compress dictionary id of body.
+func (m *Msg) CompressDict() uint32 {
+	m.checkRecycled()
+	return m.compressDict
+}
+
+// WithCompressDict sets the compress dictionary id of body.
+func (m *Msg) WithCompressDict(id uint32) {
+	m.checkRecycled()
+	m.compressDict = id
+}
+
+// RespUncompressed returns whether the server response body is left uncompressed, such as
+// skipped by compress policy, the compress type of response is CompressTypeNone then.
+func (m *Msg) RespUncompressed() bool {
+	m.checkRecycled()
+	return m.respUncompressed
+}
+
+// WithRespUncompressed sets whether the server response body is left uncompressed,
+// the compress type negotiated by request is not changed.
+func (m *Msg) WithRespUncompressed(uncompressed bool) {
+	m.checkRecycled()
+	m.respUncompressed = uncompressed
+}
+
 // CallerServiceName returns caller service name.
 func (m *Msg) CallerServiceName() string {
+	m.checkRecycled()
 	return m.callerServiceName
 }
 
 // WithCallerServiceName sets caller servie name.
 func (m *Msg) WithCallerServiceName(s string) {
+	m.checkRecycled()
 	m.callerServiceName = s
 }
 
 // CallerMethod returns callee method.
 func (m *Msg) CallerMethod() string {
+	m.checkRecycled()
 	return m.callerMethod
 }
 
 // WithCallerMethod sets callee method.
 func (m *Msg) WithCallerMethod(s string) {
+	m.checkRecycled()
 	m.callerMethod = s
 }
 
 // CalleeServiceName returns callee service name.
 func (m *Msg) CalleeServiceName() string {
+	m.checkRecycled()
 	return m.calleeServiceName
 }
 
 // WithCalleeServiceName sets callee service name.
 func (m *Msg) WithCalleeServiceName(s string) {
+	m.checkRecycled()
 	m.calleeServiceName = s
 }
 
 // CalleeMethod returns callee method.
 func (m *Msg) CalleeMethod() string {
+	m.checkRecycled()
 	return m.calleeMethod
 }
 
 // WithCalleeMethod sets callee method.
 func (m *Msg) WithCalleeMethod(s string) {
+	m.checkRecycled()
 	m.calleeMethod = s
 }
 
 // CallRPCName returns call rpc name.
 func (m *Msg) CallRPCName() string {
+	m.checkRecycled()
 	return m.callRPCName
 }
 
 // WithCallRPCName sets call rpc name.
 func (m *Msg) WithCallRPCName(s string) {
+	m.checkRecycled()
 	if m.callRPCName == s {
 		return
 	}
@@ -266,6 +537,7 @@ func (m *Msg) WithCallRPCName(s string) {
 
 // ServerRespError returns server response error.
 func (m *Msg) ServerRespError() *errs.Error {
+	m.checkRecycled()
 	if m.serverRespError == nil {
 		return nil
 	}
@@ -282,101 +554,164 @@ func (m *Msg) ServerRespError() *errs.Error {
 
 // WithServerRespError sets server response error.
 func (m *Msg) WithServerRespError(e error) {
+	m.checkRecycled()
 	m.serverRespError = e
 }
 
 // ClientRespError returns client response error, which created when client call downstream.
 func (m *Msg) ClientRespError() error {
+	m.checkRecycled()
 	return m.clientRespError
 }
 
 // WithClientRespError sets client response err
 func (m *Msg) WithClientRespError(e error) {
+	m.checkRecycled()
 	m.clientRespError = e
 }
 
 // ServerReqHead returns the package head of request
 func (m *Msg) ServerReqHead() interface{} {
+	m.checkRecycled()
 	return m.serverReqHead
 }
 
 // WithServerReqHead sets the package head of request
 func (m *Msg) WithServerReqHead(h interface{}) {
+	m.checkRecycled()
 	m.serverReqHead = h
 }
 
 // ServerRespHead returns the package head of response
 func (m *Msg) ServerRespHead() interface{} {
+	m.checkRecycled()
 	return m.serverRespHead
 }
 
 // WithServerRespHead sets the package head returns to upstream.
 func (m *Msg) WithServerRespHead(h interface{}) {
+	m.checkRecycled()
 	m.serverRespHead = h
 }
 
 // ClientReqHead returns the request package head of client,
 func (m *Msg) ClientReqHead() interface{} {
+	m.checkRecycled()
 	return m.clientReqHead
 }
 
 // WithClientReqHead sets the request package head of client.
 func (m *Msg) WithClientReqHead(h interface{}) {
+	m.checkRecycled()
 	m.clientReqHead = h
 }
 
 // ClientRespHead returns the response package head of client.
 func (m *Msg) ClientRespHead() interface{} {
+	m.checkRecycled()
 	return m.clientRespHead
 }
 
 // WithClientRespHead sets the response package head of client.
 func (m *Msg) WithClientRespHead(h interface{}) {
+	m.checkRecycled()
 	m.clientRespHead = h
 }
 
 // WithRequestID sets request id.
 func (m *Msg) WithRequestID(id uint64) {
+	m.checkRecycled()
 	m.requestID = id
 }
 
 // RequestID returns request id.
 func (m *Msg) RequestID() uint64 {
+	m.checkRecycled()
 	return m.requestID
 }
 
 // WithSpanID sets span id.
 func (m *Msg) WithSpanID(id uint64) {
+	m.checkRecycled()
 	m.spanID = id
 }
 
 // SpanID returns span id.
 func (m *Msg) SpanID() uint64 {
+	m.checkRecycled()
 	return m.spanID
 }
 
 // WithTraceID sets trace id.
 func (m *Msg) WithTraceID(id string) {
+	m.checkRecycled()
 	m.traceID = id
 }
 
 // TraceID returns trace id.
 func (m *Msg) TraceID() string {
+	m.checkRecycled()
 	return m.traceID
 }
 
+// Parent returns the server message from which the client message is derived by
+// NewClientMessage, nil if the message is not a derived client message.
+func (m *Msg) Parent() *Msg {
+	m.checkRecycled()
+	return m.parent
+}
+
+// WithParentSpanID sets parent span id.
+func (m *Msg) WithParentSpanID(id uint64) {
+	m.checkRecycled()
+	m.parentSpanID = id
+}
+
+// ParentSpanID returns parent span id, 0 means the span is the root span of trace.
+func (m *Msg) ParentSpanID() uint64 {
+	m.checkRecycled()
+	return m.parentSpanID
+}
+
+// WithTraceFlags sets trace flags, such as sampled flag of W3C trace context.
+func (m *Msg) WithTraceFlags(flags uint8) {
+	m.checkRecycled()
+	m.traceFlags = flags
+}
+
+// TraceFlags returns trace flags.
+func (m *Msg) TraceFlags() uint8 {
+	m.checkRecycled()
+	return m.traceFlags
+}
+
+// WithTraceState sets trace state, which is the vendor-specific trace information of W3C tracestate.
+func (m *Msg) WithTraceState(state string) {
+	m.checkRecycled()
+	m.traceState = state
+}
+
+// TraceState returns trace state.
+func (m *Msg) TraceState() string {
+	m.checkRecycled()
+	return m.traceState
+}
+
 // WithLogger sets log into context message.
 func (m *Msg) WithLogger(l logger.Logger) {
+	m.checkRecycled()
 	m.logger = l
 }
 
 // Logger returns log from context message.
 func (m *Msg) Logger() logger.Logger {
+	m.checkRecycled()
 	return m.logger
 }
 
 // LogSeq returns logger sequence
 func (m *Msg) LogSeq() int {
+	m.checkRecycled()
 	m.logSeq++
 
 	if m.logSeq > 999999999 {
@@ -386,20 +721,25 @@ func (m *Msg) LogSeq() int {
 	return m.logSeq
 }
 
-// CopyMsg copy src Msg to dst.
+// CopyMsg copy src Msg to dst, all fields except context and the recycle state are copied,
+// and metadata is deep copied.
 func CopyMsg(dst, src *Msg) {
 	if dst == nil || src == nil {
 		return
 	}
 	dst.WithFrameCodec(src.FrameCodec())
 	dst.WithRequestTimeout(src.RequestTimeout())
+	dst.WithReceiveTime(src.ReceiveTime())
 	dst.WithSerializationType(src.SerializationType())
+	dst.WithCompressType(src.CompressType())
+	dst.WithCompressDict(src.CompressDict())
+	dst.WithRespUncompressed(src.RespUncompressed())
 	dst.WithCallerServiceName(src.CallerServiceName())
 	dst.WithCallerMethod(src.CallerMethod())
 	dst.WithCalleeServiceName(src.CalleeServiceName())
 	dst.WithCalleeMethod(src.CalleeMethod())
-	dst.WithCallRPCName(src.CallRPCName())
-	dst.WithServerRespError(src.ServerRespError())
+	dst.callRPCName = src.CallRPCName() // not by WithCallRPCName, which overwrites the callee.
+	dst.serverRespError = src.serverRespError
 	dst.WithClientRespError(src.ClientRespError())
 	dst.WithServerReqHead(src.ServerReqHead())
 	dst.WithServerRespHead(src.ServerRespHead())
@@ -409,12 +749,30 @@ func CopyMsg(dst, src *Msg) {
 	dst.WithRemoteAddr(src.RemoteAddr())
 	dst.WithLogger(src.Logger())
 	dst.WithEnv(src.Env())
+	dst.WithMetaData(copyMetaData(src.MetaData()))
 	dst.WithRequestID(src.RequestID())
 	dst.WithSpanID(src.SpanID())
 	dst.WithTraceID(src.TraceID())
+	dst.WithParentSpanID(src.ParentSpanID())
+	dst.WithTraceFlags(src.TraceFlags())
+	dst.WithTraceState(src.TraceState())
+	dst.parent = src.Parent()
 	dst.logSeq = src.logSeq
 }
 
+// copyMetaData returns a copy of metadata, so that the copied msg can modify it independently.
+func copyMetaData(md map[string]string) map[string]string {
+	if md == nil {
+		return nil
+	}
+
+	ret := make(map[string]string, len(md))
+	for k, v := range md {
+		ret[k] = v
+	}
+	return ret
+}
+
 type detachedContext struct{ parent context.Context }
 
 func detach(ctx context.Context) context.Context { return detachedContext{ctx} }
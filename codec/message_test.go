@@ -0,0 +1,221 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/horm-database/common/log/logger"
+)
+
+// msgRecycleState are the fields of recycle state, which are neither reset nor copied.
+var msgRecycleState = map[string]bool{"generation": true, "poisoned": true}
+
+// fillMsg sets every field of msg to a non-zero value by reflection, so that the new field
+// not handled by reset or CopyMsg fails the tests.
+func fillMsg(t *testing.T, m *Msg) {
+	v := reflect.ValueOf(m).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if msgRecycleState[name] {
+			continue
+		}
+
+		f := v.Field(i)
+		f = reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString("value of " + name)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f.SetInt(int64(i + 1))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f.SetUint(uint64(i + 1))
+		case reflect.Bool:
+			f.SetBool(true)
+		case reflect.Map:
+			f.Set(reflect.ValueOf(map[string]string{"key": name}))
+		case reflect.Ptr:
+			f.Set(reflect.New(f.Type().Elem()))
+		case reflect.Struct:
+			f.Set(reflect.ValueOf(time.Unix(int64(i+1), 0)))
+		case reflect.Interface:
+			f.Set(reflect.ValueOf(sampleOf(t, name, f.Type())))
+		default:
+			t.Fatalf("no sample value for field %s of kind %s", name, f.Kind())
+		}
+	}
+}
+
+func sampleOf(t *testing.T, name string, typ reflect.Type) interface{} {
+	samples := []interface{}{
+		context.Background(),
+		errors.New("error of " + name),
+		&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: len(name)},
+		logger.DefaultLogger,
+		&struct{ Name string }{name},
+	}
+
+	for _, s := range samples {
+		if reflect.TypeOf(s).Implements(typ) {
+			return s
+		}
+	}
+
+	t.Fatalf("no sample value for field %s of type %s", name, typ)
+	return nil
+}
+
+func fieldOf(m *Msg, i int) interface{} {
+	f := reflect.ValueOf(m).Elem().Field(i)
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem().Interface()
+}
+
+func TestResetMsg(t *testing.T) {
+	m := &Msg{}
+	fillMsg(t, m)
+	m.reset()
+
+	v := reflect.ValueOf(m).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if !msgRecycleState[name] && !v.Field(i).IsZero() {
+			t.Errorf("field %s is not reset", name)
+		}
+	}
+}
+
+func TestCopyMsg(t *testing.T) {
+	src, dst := &Msg{}, &Msg{}
+	fillMsg(t, src)
+	CopyMsg(dst, src)
+
+	v := reflect.ValueOf(src).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if name == "context" || msgRecycleState[name] {
+			continue
+		}
+
+		if !reflect.DeepEqual(fieldOf(dst, i), fieldOf(src, i)) {
+			t.Errorf("field %s is not copied, expect %v, got %v", name, fieldOf(src, i), fieldOf(dst, i))
+		}
+	}
+
+	dst.WithMetaValue("key", "changed")
+	if src.MetaValue("key") == "changed" {
+		t.Error("metadata is shared between copied messages")
+	}
+}
+
+func TestRecycleMessage(t *testing.T) {
+	_, m := NewMessage(context.Background())
+	m.WithTraceID("trace")
+	m.WithSpanID(1)
+
+	gen := m.Generation()
+	RecycleMessage(m)
+
+	if m.Generation() != gen+1 {
+		t.Errorf("generation expect %d, got %d", gen+1, m.Generation())
+	}
+
+	if m.TraceID() != "" || m.SpanID() != 0 {
+		t.Error("trace id and span id are not reset")
+	}
+}
+
+func TestNewClientMessage(t *testing.T) {
+	ctx, server := NewMessage(context.Background())
+	server.WithCallerServiceName("upstream")
+	server.WithCallRPCName("service/method")
+	server.WithServerReqHead("server request head")
+	server.WithTraceID("trace")
+	server.WithSpanID(1)
+	server.WithMetaValue("tenant", "t1")
+	server.WithMetaValue(metaTraceParent, "traceparent of upstream")
+	server.WithMetaValue(metaTraceState, "tracestate of upstream")
+
+	cctx1, client1 := NewClientMessage(ctx)
+	_, client2 := NewClientMessage(ctx)
+
+	if Message(cctx1) != client1 || client1.Parent() != server || client2.Parent() != server {
+		t.Fatal("client message is not linked to the server message")
+	}
+
+	if client1.CallerServiceName() != "service" || client1.CallerMethod() != "method" ||
+		client1.CallRPCName() != "" || client1.ServerReqHead() != nil {
+		t.Error("caller, callee or server head of client message is not derived from server message")
+	}
+
+	if client1.TraceID() != "trace" || client1.MetaValue("tenant") != "t1" {
+		t.Error("trace id or metadata is not inherited from server message")
+	}
+
+	if client1.SpanID() == 0 || client1.SpanID() == server.SpanID() || client1.SpanID() == client2.SpanID() ||
+		client1.ParentSpanID() != server.SpanID() {
+		t.Error("client message is not a child span of server message")
+	}
+
+	if client1.MetaValue(metaTraceParent) != "" || client1.MetaValue(metaTraceState) != "" ||
+		server.MetaValue(metaTraceParent) == "" {
+		t.Error("trace context of upstream is propagated by client message")
+	}
+
+	client1.WithClientReqHead("request head 1")
+	client1.WithClientRespError(errors.New("error 1"))
+	client2.WithClientReqHead("request head 2")
+
+	if client2.ClientRespError() != nil || client2.ClientReqHead() != "request head 2" ||
+		server.ClientReqHead() != nil || server.ClientRespError() != nil {
+		t.Error("client heads and errors are not isolated")
+	}
+}
+
+func TestRecycleMessageDebug(t *testing.T) {
+	SetMsgDebugMode(MsgDebugPanic)
+	defer SetMsgDebugMode(MsgDebugOff)
+
+	_, m := NewMessage(context.Background())
+	RecycleMessage(m)
+
+	defer func() {
+		if e := recover(); e != ErrMsgRecycled {
+			t.Errorf("access to recycled msg expect panic %v, got %v", ErrMsgRecycled, e)
+		}
+	}()
+
+	_ = m.TraceID()
+}
+
+func TestMessageLegacyKey(t *testing.T) {
+	m := &Msg{}
+	ctx := context.WithValue(context.Background(), ContextMsg, m)
+	if Message(ctx) != m {
+		t.Fatal("message put under the deprecated ContextMsg key is not found")
+	}
+
+	ctx, msg := NewMessage(ctx)
+	defer RecycleMessage(msg)
+
+	if Message(ctx) != msg {
+		t.Fatal("message put by NewMessage should take precedence over the deprecated key")
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/horm-database/common/json"
+	"github.com/vmihailenco/msgpack/v5"
+
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+const ( // serialization type of body
+	SerializationTypeJSON   = 0 // json, default
+	SerializationTypePB     = 1 // protobuf
+	SerializationTypeBinary = 2 // compact binary, encoded by msgpack
+)
+
+var (
+	ErrNotProtoMessage = errors.New("protobuf serializer: value is not proto.Message")
+)
+
+// Serializer marshals and unmarshals the body, it's goroutine-safe.
+type Serializer interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	serializers     = map[int]Serializer{}
+	lockSerializers = sync.RWMutex{}
+)
+
+func init() {
+	RegisterSerializer(SerializationTypeJSON, &JSONSerializer{})
+	RegisterSerializer(SerializationTypePB, &PBSerializer{})
+	RegisterSerializer(SerializationTypeBinary, &BinarySerializer{})
+}
+
+// RegisterSerializer registers the serializer of serialization type.
+func RegisterSerializer(serializationType int, s Serializer) {
+	lockSerializers.Lock()
+	serializers[serializationType] = s
+	lockSerializers.Unlock()
+}
+
+// GetSerializer returns the serializer of serialization type.
+func GetSerializer(serializationType int) Serializer {
+	lockSerializers.RLock()
+	s := serializers[serializationType]
+	lockSerializers.RUnlock()
+	return s
+}
+
+// Marshal marshals v by the serializer of serialization type.
+func Marshal(serializationType int, v interface{}) ([]byte, error) {
+	s := GetSerializer(serializationType)
+	if s == nil {
+		return nil, fmt.Errorf("serializer of serialization type %d not registered", serializationType)
+	}
+	return s.Marshal(v)
+}
+
+// Unmarshal unmarshals data into v by the serializer of serialization type.
+func Unmarshal(serializationType int, data []byte, v interface{}) error {
+	s := GetSerializer(serializationType)
+	if s == nil {
+		return fmt.Errorf("serializer of serialization type %d not registered", serializationType)
+	}
+	return s.Unmarshal(data, v)
+}
+
+// MarshalBody marshals the body v by serialization type of msg.
+func MarshalBody(msg *Msg, v interface{}) ([]byte, error) {
+	return Marshal(msg.SerializationType(), v)
+}
+
+// UnmarshalBody unmarshals the body data into v by serialization type of msg.
+func UnmarshalBody(msg *Msg, data []byte, v interface{}) error {
+	return Unmarshal(msg.SerializationType(), data, v)
+}
+
+// JSONSerializer is the json serializer, which uses json.Api.
+type JSONSerializer struct{}
+
+// Marshal implements Serializer.
+func (s *JSONSerializer) Marshal(v interface{}) ([]byte, error) {
+	return json.Api.Marshal(v)
+}
+
+// Unmarshal implements Serializer.
+func (s *JSONSerializer) Unmarshal(data []byte, v interface{}) error {
+	return json.Api.Unmarshal(data, v)
+}
+
+// PBSerializer is the protobuf serializer, v must be proto.Message.
+type PBSerializer struct{}
+
+// Marshal implements Serializer.
+func (s *PBSerializer) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(protobuf.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return protobuf.Marshal(m)
+}
+
+// Unmarshal implements Serializer.
+func (s *PBSerializer) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(protobuf.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	return protobuf.Unmarshal(data, m)
+}
+
+// BinarySerializer is the compact binary serializer encoded by msgpack, which is schemaless
+// like json, so that the value encoded from struct can be decoded into map and vice versa.
+// The keys of struct fields are the names in json tag, the same as JSONSerializer, and
+// integers are encoded in the smallest size that holds the value.
+type BinarySerializer struct{}
+
+// Marshal implements Serializer.
+func (s *BinarySerializer) Marshal(v interface{}) ([]byte, error) {
+	buf := bytes.Buffer{}
+
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+
+	enc.Reset(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal implements Serializer.
+func (s *BinarySerializer) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(dec)
+
+	dec.Reset(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
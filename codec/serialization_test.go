@@ -0,0 +1,70 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBinarySerializer(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+		Age  uint8  `json:"age"`
+	}
+
+	s := GetSerializer(SerializationTypeBinary)
+	tests := []struct {
+		name string
+		in   interface{}
+		out  interface{} // value to decode into
+		want interface{}
+	}{
+		{"map", map[string]interface{}{"id": 1, "name": "horm", "age": 18},
+			&map[string]interface{}{}, &map[string]interface{}{"id": int8(1), "name": "horm", "age": int8(18)}},
+		{"struct to struct", user{ID: 1, Name: "horm", Age: 18}, &user{}, &user{ID: 1, Name: "horm", Age: 18}},
+		{"struct to map", user{ID: 1, Name: "horm", Age: 18},
+			&map[string]interface{}{}, &map[string]interface{}{"id": int8(1), "name": "horm", "age": int8(18)}},
+		{"map to struct", map[string]interface{}{"id": 1, "name": "horm", "age": 18},
+			&user{}, &user{ID: 1, Name: "horm", Age: 18}},
+		{"slice", []interface{}{"a", 1, true}, &[]interface{}{}, &[]interface{}{"a", int8(1), true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := s.Marshal(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jsonData, err := GetSerializer(SerializationTypeJSON).Marshal(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(data) >= len(jsonData) {
+				t.Errorf("binary size %d is not smaller than json size %d", len(data), len(jsonData))
+			}
+
+			if err = s.Unmarshal(data, tt.out); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tt.out, tt.want) {
+				t.Fatalf("unmarshal got %#v, want %#v", tt.out, tt.want)
+			}
+		})
+	}
+}
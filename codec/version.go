@@ -0,0 +1,117 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"fmt"
+)
+
+// FrameVersions declares the versions supported of each frame type, the versions
+// of frame type are in ascending order, such as:
+//
+//	FrameVersions{
+//		FrameTypeNormal:    {ProtocolVersion},
+//		FrameTypeSignature: {SignVersion, SignVersionHMAC},
+//	}
+//
+// The server declares the versions it accepts, and rejects frames of other versions
+// by VersionError, the client then downgrades to the highest version both support
+// by Negotiate, so that new frame versions can roll out without a flag day.
+//
+// Control frames of ControlVersion are always supported, even if FrameTypeControl is absent,
+// since they are required for connection management, such as heartbeat and VersionNotSupported.
+type FrameVersions map[uint8][]uint8
+
+// SupportedFrameVersions returns all frame versions supported by this package.
+func SupportedFrameVersions() FrameVersions {
+	return FrameVersions{
+		FrameTypeNormal:    {ProtocolVersion},
+		FrameTypeSignature: {SignVersion, SignVersionHMAC},
+		FrameTypeEncrypt:   {EncryptVersion, EncryptVersionGCM},
+		FrameTypeControl:   {ControlVersion},
+	}
+}
+
+// Support returns whether the version of frame type is supported, control frames of
+// ControlVersion are always supported.
+func (v FrameVersions) Support(frameType, version uint8) bool {
+	if frameType == FrameTypeControl && version == ControlVersion {
+		return true
+	}
+
+	for _, supported := range v[frameType] {
+		if supported == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate returns the highest version of frame type supported by both sides,
+// remote is the versions supported by the peer, such as VersionError.Supported.
+func (v FrameVersions) Negotiate(frameType uint8, remote []uint8) (uint8, bool) {
+	var version uint8
+	var found bool
+
+	for _, local := range v[frameType] {
+		for _, r := range remote {
+			if local == r && (!found || local > version) {
+				version, found = local, true
+			}
+		}
+	}
+
+	return version, found
+}
+
+// VersionError is returned when the version of frame is not supported.
+type VersionError struct {
+	FrameType uint8   // frame type
+	Version   uint8   // the rejected version
+	Supported []uint8 // versions of frame type supported
+}
+
+// Error implements error.
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("%s: frame type %d version %d, supported versions %v",
+		ErrFrameVersion.Error(), e.FrameType, e.Version, e.Supported)
+}
+
+// Is makes errors.Is(err, ErrFrameVersion) true.
+func (e *VersionError) Is(target error) bool {
+	return target == ErrFrameVersion
+}
+
+// NewVersionNotSupportedFrame create a control frame that replies the frame of unsupported version.
+func NewVersionNotSupportedFrame(e *VersionError) *ControlFrame {
+	data := make([]byte, 2+len(e.Supported))
+	data[0] = e.FrameType
+	data[1] = e.Version
+	copy(data[2:], e.Supported)
+
+	return &ControlFrame{ControlType: ControlTypeVersionNotSupported, Data: data}
+}
+
+// VersionError parses the VersionError from the control frame of ControlTypeVersionNotSupported.
+func (c *ControlFrame) VersionError() (*VersionError, error) {
+	if c.ControlType != ControlTypeVersionNotSupported || len(c.Data) < 2 {
+		return nil, ErrControlFrame
+	}
+
+	return &VersionError{
+		FrameType: c.Data[0],
+		Version:   c.Data[1],
+		Supported: append([]uint8(nil), c.Data[2:]...),
+	}, nil
+}
@@ -17,8 +17,6 @@ package compress
 import (
 	"bytes"
 	"compress/gzip"
-	"io"
-	"io/ioutil"
 	"sync"
 
 	"github.com/horm-database/common/json"
@@ -54,51 +52,21 @@ func JsonMarshalAndCompress(v interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// DecompressJsonUnmarshal decompression and json decode
+// DecompressJsonUnmarshal decompression and json decode, in is decoded as it is if it is not
+// compressed, which is detected by magic bytes. The decompressed size is limited by MaxDecompressSize,
+// and the error of decompressing has code errs.ErrServerDecompress.
 func DecompressJsonUnmarshal(in []byte, v interface{}) error {
-	gzipReader, ok := gzipReaderPool.Get().(*gzip.Reader)
-
-	defer func() {
-		if gzipReader != nil {
-			gzipReaderPool.Put(gzipReader)
-		}
-	}()
-
-	var err error
-	if ok {
-		err = gzipReader.Reset(bytes.NewReader(in))
-	} else {
-		gzipReader, err = gzip.NewReader(bytes.NewReader(in))
-	}
-
-	var reader io.Reader = gzipReader
+	out, err := Decompress(in)
 	if err != nil {
-		reader = bytes.NewReader(in)
+		return err
 	}
 
-	return json.Api.NewDecoder(reader).Decode(v)
+	return json.Api.Unmarshal(out, v)
 }
 
+// Decompress decompresses in by the compress type detected by magic bytes, in is returned
+// as it is if it is not compressed. The decompressed size is limited by MaxDecompressSize,
+// and the error of decompressing has code errs.ErrServerDecompress.
 func Decompress(in []byte) ([]byte, error) {
-	gzipReader, ok := gzipReaderPool.Get().(*gzip.Reader)
-
-	defer func() {
-		if gzipReader != nil {
-			gzipReaderPool.Put(gzipReader)
-		}
-	}()
-
-	var err error
-	if ok {
-		err = gzipReader.Reset(bytes.NewReader(in))
-	} else {
-		gzipReader, err = gzip.NewReader(bytes.NewReader(in))
-	}
-
-	var reader io.Reader = gzipReader
-	if err != nil {
-		reader = bytes.NewReader(in)
-	}
-
-	return ioutil.ReadAll(reader)
+	return DecompressLimit(DetectCompressType(in), in, MaxDecompressSize)
 }
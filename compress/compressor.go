@@ -0,0 +1,358 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"sync"
+
+	"github.com/horm-database/common/consts"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// Compressor compresses and decompresses the body, it's goroutine-safe.
+type Compressor interface {
+	Compress(in []byte) ([]byte, error)
+	Decompress(in []byte) ([]byte, error)
+}
+
+const (
+	DefaultLevel   = -1 // default compression level of compressor.
+	BestSpeedLevel = 1  // the fastest compression level of compressor.
+)
+
+// LevelCompressor is implemented by the compressor which supports compression level.
+type LevelCompressor interface {
+	CompressLevel(in []byte, level int) ([]byte, error)
+}
+
+var (
+	compressors     = map[uint32]Compressor{}
+	lockCompressors = sync.RWMutex{}
+)
+
+func init() {
+	RegisterCompressor(consts.CompressTypeGzip, &GzipCompressor{})
+	RegisterCompressor(consts.CompressTypeZstd, &ZstdCompressor{})
+	RegisterCompressor(consts.CompressTypeSnappy, &SnappyCompressor{})
+	RegisterCompressor(consts.CompressTypeLZ4, &LZ4Compressor{})
+}
+
+// RegisterCompressor registers the compressor of compress type, which is
+// the value of Compress in request and response header.
+func RegisterCompressor(compressType uint32, c Compressor) {
+	lockCompressors.Lock()
+	compressors[compressType] = c
+	lockCompressors.Unlock()
+}
+
+// GetCompressor returns the compressor of compress type.
+func GetCompressor(compressType uint32) Compressor {
+	lockCompressors.RLock()
+	c := compressors[compressType]
+	lockCompressors.RUnlock()
+	return c
+}
+
+// CompressBy compresses in by the compressor of compress type,
+// in is returned as it is if compress type is CompressTypeNone.
+func CompressBy(compressType uint32, in []byte) ([]byte, error) {
+	if compressType == consts.CompressTypeNone || len(in) == 0 {
+		return in, nil
+	}
+
+	c := GetCompressor(compressType)
+	if c == nil {
+		return nil, fmt.Errorf("compressor of compress type %d not registered", compressType)
+	}
+
+	return c.Compress(in)
+}
+
+// DecompressBy decompresses in by the compressor of compress type, the decompressed size is
+// limited by MaxDecompressSize. in is returned as it is if compress type is CompressTypeNone.
+func DecompressBy(compressType uint32, in []byte) ([]byte, error) {
+	return DecompressLimit(compressType, in, MaxDecompressSize)
+}
+
+// GzipCompressor is the gzip compressor, which shares the gzip pools with JsonMarshalAndCompress.
+type GzipCompressor struct{}
+
+// Compress implements Compressor.
+func (c *GzipCompressor) Compress(in []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	gzipWriter, ok := gzipWriterPool.Get().(*gzip.Writer)
+	if !ok {
+		gzipWriter = gzip.NewWriter(buf)
+	} else {
+		gzipWriter.Reset(buf)
+	}
+
+	defer gzipWriterPool.Put(gzipWriter)
+
+	if _, err := gzipWriter.Write(in); err != nil {
+		_ = gzipWriter.Close()
+		return nil, err
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// gzipLevelWriterPools are the gzip writer pools of level HuffmanOnly to BestCompression.
+var gzipLevelWriterPools [gzip.BestCompression - gzip.HuffmanOnly + 1]sync.Pool
+
+// CompressLevel implements LevelCompressor, level is from gzip.HuffmanOnly to gzip.BestCompression.
+func (c *GzipCompressor) CompressLevel(in []byte, level int) ([]byte, error) {
+	if level == DefaultLevel {
+		return c.Compress(in)
+	}
+
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, fmt.Errorf("invalid gzip compression level %d", level)
+	}
+
+	buf := &bytes.Buffer{}
+	pool := &gzipLevelWriterPools[level-gzip.HuffmanOnly]
+
+	gzipWriter, ok := pool.Get().(*gzip.Writer)
+	if !ok {
+		gzipWriter, _ = gzip.NewWriterLevel(buf, level)
+	} else {
+		gzipWriter.Reset(buf)
+	}
+
+	defer pool.Put(gzipWriter)
+
+	if _, err := gzipWriter.Write(in); err != nil {
+		_ = gzipWriter.Close()
+		return nil, err
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decompress implements Compressor.
+func (c *GzipCompressor) Decompress(in []byte) ([]byte, error) {
+	return c.DecompressLimit(in, MaxDecompressSize)
+}
+
+// DecompressLimit implements LimitDecompressor.
+func (c *GzipCompressor) DecompressLimit(in []byte, maxSize int64) ([]byte, error) {
+	gzipReader, ok := gzipReaderPool.Get().(*gzip.Reader)
+
+	var err error
+	if ok {
+		err = gzipReader.Reset(bytes.NewReader(in))
+	} else {
+		gzipReader, err = gzip.NewReader(bytes.NewReader(in))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer gzipReaderPool.Put(gzipReader)
+
+	return readAll(gzipReader, maxSize)
+}
+
+var (
+	zstdEncoder, _  = zstd.NewWriter(nil)
+	zstdDecoderPool = sync.Pool{}
+
+	zstdLevelEncoders     = map[zstd.EncoderLevel]*zstd.Encoder{}
+	lockZstdLevelEncoders = sync.RWMutex{}
+)
+
+// ZstdCompressor is the zstd compressor.
+type ZstdCompressor struct{}
+
+// Compress implements Compressor.
+func (c *ZstdCompressor) Compress(in []byte) ([]byte, error) {
+	return zstdEncoder.EncodeAll(in, nil), nil
+}
+
+// CompressLevel implements LevelCompressor, level is the zstd compression level 1-22,
+// which is mapped to the nearest encoder level.
+func (c *ZstdCompressor) CompressLevel(in []byte, level int) ([]byte, error) {
+	if level == DefaultLevel {
+		return c.Compress(in)
+	}
+
+	encoderLevel := zstd.EncoderLevelFromZstd(level)
+
+	lockZstdLevelEncoders.RLock()
+	encoder, ok := zstdLevelEncoders[encoderLevel]
+	lockZstdLevelEncoders.RUnlock()
+
+	if !ok {
+		lockZstdLevelEncoders.Lock()
+		encoder, ok = zstdLevelEncoders[encoderLevel]
+		if !ok {
+			var err error
+			encoder, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(encoderLevel))
+			if err != nil {
+				lockZstdLevelEncoders.Unlock()
+				return nil, err
+			}
+			zstdLevelEncoders[encoderLevel] = encoder
+		}
+		lockZstdLevelEncoders.Unlock()
+	}
+
+	return encoder.EncodeAll(in, nil), nil
+}
+
+// Decompress implements Compressor.
+func (c *ZstdCompressor) Decompress(in []byte) ([]byte, error) {
+	return c.DecompressLimit(in, MaxDecompressSize)
+}
+
+// DecompressLimit implements LimitDecompressor.
+func (c *ZstdCompressor) DecompressLimit(in []byte, maxSize int64) ([]byte, error) {
+	zstdDecoder, ok := zstdDecoderPool.Get().(*zstd.Decoder)
+
+	var err error
+	if ok {
+		err = zstdDecoder.Reset(bytes.NewReader(in))
+	} else {
+		// decoder without concurrency decodes synchronously, so that it can be pooled.
+		zstdDecoder, err = zstd.NewReader(bytes.NewReader(in), zstd.WithDecoderConcurrency(1))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer zstdDecoderPool.Put(zstdDecoder)
+
+	return readAll(zstdDecoder, maxSize)
+}
+
+var (
+	snappyWriterPool = sync.Pool{}
+	snappyReaderPool = sync.Pool{}
+)
+
+// SnappyCompressor is the snappy compressor, which uses the snappy framing format.
+type SnappyCompressor struct{}
+
+// Compress implements Compressor.
+func (c *SnappyCompressor) Compress(in []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	snappyWriter, ok := snappyWriterPool.Get().(*snappy.Writer)
+	if !ok {
+		snappyWriter = snappy.NewBufferedWriter(buf)
+	} else {
+		snappyWriter.Reset(buf)
+	}
+
+	defer snappyWriterPool.Put(snappyWriter)
+
+	if _, err := snappyWriter.Write(in); err != nil {
+		_ = snappyWriter.Close()
+		return nil, err
+	}
+
+	if err := snappyWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decompress implements Compressor.
+func (c *SnappyCompressor) Decompress(in []byte) ([]byte, error) {
+	return c.DecompressLimit(in, MaxDecompressSize)
+}
+
+// DecompressLimit implements LimitDecompressor.
+func (c *SnappyCompressor) DecompressLimit(in []byte, maxSize int64) ([]byte, error) {
+	snappyReader, ok := snappyReaderPool.Get().(*snappy.Reader)
+	if !ok {
+		snappyReader = snappy.NewReader(bytes.NewReader(in))
+	} else {
+		snappyReader.Reset(bytes.NewReader(in))
+	}
+
+	defer snappyReaderPool.Put(snappyReader)
+
+	return readAll(snappyReader, maxSize)
+}
+
+var (
+	lz4WriterPool = sync.Pool{}
+	lz4ReaderPool = sync.Pool{}
+)
+
+// LZ4Compressor is the lz4 compressor, which uses the lz4 frame format.
+type LZ4Compressor struct{}
+
+// Compress implements Compressor.
+func (c *LZ4Compressor) Compress(in []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	lz4Writer, ok := lz4WriterPool.Get().(*lz4.Writer)
+	if !ok {
+		lz4Writer = lz4.NewWriter(buf)
+	} else {
+		lz4Writer.Reset(buf)
+	}
+
+	defer lz4WriterPool.Put(lz4Writer)
+
+	if _, err := lz4Writer.Write(in); err != nil {
+		_ = lz4Writer.Close()
+		return nil, err
+	}
+
+	if err := lz4Writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decompress implements Compressor.
+func (c *LZ4Compressor) Decompress(in []byte) ([]byte, error) {
+	return c.DecompressLimit(in, MaxDecompressSize)
+}
+
+// DecompressLimit implements LimitDecompressor.
+func (c *LZ4Compressor) DecompressLimit(in []byte, maxSize int64) ([]byte, error) {
+	lz4Reader, ok := lz4ReaderPool.Get().(*lz4.Reader)
+	if !ok {
+		lz4Reader = lz4.NewReader(bytes.NewReader(in))
+	} else {
+		lz4Reader.Reset(bytes.NewReader(in))
+	}
+
+	defer lz4ReaderPool.Put(lz4Reader)
+
+	return readAll(lz4Reader, maxSize)
+}
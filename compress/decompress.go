@@ -0,0 +1,118 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/horm-database/common/consts"
+	"github.com/horm-database/common/errs"
+)
+
+// MaxDecompressSize is the default max size of decompressed data, which protects
+// the server from decompression bomb.
+var MaxDecompressSize int64 = 64 * 1024 * 1024
+
+var (
+	ErrDecompressTooLarge = errs.New(errs.ErrServerDecompress, "decompressed data exceeds the max size")
+)
+
+// LimitDecompressor is implemented by the compressor which stops decompressing
+// as soon as the decompressed data exceeds max size. The data decompressed by compressor
+// not implementing it is checked after the whole data has been decompressed.
+type LimitDecompressor interface {
+	DecompressLimit(in []byte, maxSize int64) ([]byte, error)
+}
+
+// magic bytes of the built-in compress types.
+var magics = map[uint32][]byte{
+	consts.CompressTypeGzip:   {0x1f, 0x8b},
+	consts.CompressTypeZstd:   {0x28, 0xb5, 0x2f, 0xfd},
+	consts.CompressTypeSnappy: {0xff, 0x06, 0x00, 0x00, 's', 'N', 'a', 'P', 'p', 'Y'},
+	consts.CompressTypeLZ4:    {0x04, 0x22, 0x4d, 0x18},
+}
+
+// DetectCompressType detects the built-in compress type of data by its magic bytes,
+// returns CompressTypeNone if data is not compressed by any of them.
+func DetectCompressType(in []byte) uint32 {
+	for compressType, magic := range magics {
+		if bytes.HasPrefix(in, magic) {
+			return compressType
+		}
+	}
+	return consts.CompressTypeNone
+}
+
+// DecompressLimit decompresses in by the compressor of compress type, and fails with
+// ErrDecompressTooLarge if the decompressed data exceeds maxSize. The magic bytes of the
+// built-in compress types are checked before decompressing. in is returned as it is
+// if compress type is CompressTypeNone. All errors have code errs.ErrServerDecompress.
+func DecompressLimit(compressType uint32, in []byte, maxSize int64) ([]byte, error) {
+	if compressType == consts.CompressTypeNone || len(in) == 0 {
+		return in, nil
+	}
+
+	c := GetCompressor(compressType)
+	if c == nil {
+		return nil, errs.Newf(errs.ErrServerDecompress,
+			"compressor of compress type %d not registered", compressType)
+	}
+
+	if magic, ok := magics[compressType]; ok && !bytes.HasPrefix(in, magic) {
+		return nil, errs.Newf(errs.ErrServerDecompress,
+			"data is not compressed by compress type %d, magic bytes mismatch", compressType)
+	}
+
+	var out []byte
+	var err error
+
+	if ld, ok := c.(LimitDecompressor); ok {
+		out, err = ld.DecompressLimit(in, maxSize)
+	} else {
+		out, err = c.Decompress(in)
+		if err == nil && int64(len(out)) > maxSize {
+			err = ErrDecompressTooLarge
+		}
+	}
+
+	if err != nil {
+		return nil, decompressError(compressType, err)
+	}
+
+	return out, nil
+}
+
+// readAll reads all data from r, fails with ErrDecompressTooLarge if it exceeds maxSize.
+func readAll(r io.Reader, maxSize int64) ([]byte, error) {
+	buf := bytes.Buffer{}
+	n, err := buf.ReadFrom(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if n > maxSize {
+		return nil, ErrDecompressTooLarge
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decompressError maps the error of decompressing to errs.ErrServerDecompress.
+func decompressError(compressType uint32, err error) error {
+	if _, ok := err.(*errs.Error); ok {
+		return err
+	}
+	return errs.Newf(errs.ErrServerDecompress, "corrupt data of compress type %d: %v", compressType, err)
+}
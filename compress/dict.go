@@ -0,0 +1,287 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/horm-database/common/consts"
+	"github.com/horm-database/common/errs"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	DefaultDictSize   = 16 * 1024 // default max size of the trained dictionary.
+	dictSegmentLength = 16        // length of the segments counted when training dictionary.
+)
+
+var (
+	ErrDictID          = errors.New("dictionary id must not be 0")
+	ErrDictNotFound    = errs.New(errs.ErrServerDecompress, "compress dictionary not found")
+	ErrDictUnsupported = errors.New("compress type does not support dictionary")
+)
+
+// DictCompressor is implemented by the compressor which supports shared dictionary.
+// The dictionary id is carried in CompressDict of request/response header alongside Compress.
+type DictCompressor interface {
+	CompressDict(in []byte, dict *Dictionary) ([]byte, error)
+	DecompressDict(in []byte, dict *Dictionary, maxSize int64) ([]byte, error)
+}
+
+// Dictionary is the shared dictionary of compression, both sides must register the same
+// content with the same id. It is used as initial history, so that small json payloads with
+// repetitive keys, such as name, op, where and rsp_data, can be compressed well.
+type Dictionary struct {
+	ID      uint32
+	Content []byte
+
+	zstdEncoder     *zstd.Encoder
+	zstdDecoderPool sync.Pool
+}
+
+var (
+	dictionaries     = map[uint32]*Dictionary{}
+	lockDictionaries = sync.RWMutex{}
+)
+
+// RegisterDictionary registers the dictionary content with id, the dictionary of the same id is replaced.
+func RegisterDictionary(id uint32, content []byte) error {
+	if id == 0 {
+		return ErrDictID
+	}
+
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderDictRaw(id, content))
+	if err != nil {
+		return err
+	}
+
+	dict := &Dictionary{ID: id, Content: content, zstdEncoder: encoder}
+
+	lockDictionaries.Lock()
+	dictionaries[id] = dict
+	lockDictionaries.Unlock()
+
+	return nil
+}
+
+// GetDictionary returns the dictionary of id.
+func GetDictionary(id uint32) *Dictionary {
+	lockDictionaries.RLock()
+	dict := dictionaries[id]
+	lockDictionaries.RUnlock()
+	return dict
+}
+
+// CompressWithDict compresses in by the compressor of compress type with the dictionary of dictID,
+// it is the same as CompressBy if dictID is 0.
+func CompressWithDict(compressType, dictID uint32, in []byte) ([]byte, error) {
+	if dictID == 0 || compressType == consts.CompressTypeNone || len(in) == 0 {
+		return CompressBy(compressType, in)
+	}
+
+	dc, ok := GetCompressor(compressType).(DictCompressor)
+	if !ok {
+		return nil, ErrDictUnsupported
+	}
+
+	dict := GetDictionary(dictID)
+	if dict == nil {
+		return nil, ErrDictNotFound
+	}
+
+	return dc.CompressDict(in, dict)
+}
+
+// DecompressWithDict decompresses in by the compressor of compress type with the dictionary of dictID,
+// it is the same as DecompressBy if dictID is 0. The decompressed size is limited by MaxDecompressSize,
+// and all errors have code errs.ErrServerDecompress.
+func DecompressWithDict(compressType, dictID uint32, in []byte) ([]byte, error) {
+	if dictID == 0 || compressType == consts.CompressTypeNone || len(in) == 0 {
+		return DecompressBy(compressType, in)
+	}
+
+	dc, ok := GetCompressor(compressType).(DictCompressor)
+	if !ok {
+		return nil, decompressError(compressType, ErrDictUnsupported)
+	}
+
+	dict := GetDictionary(dictID)
+	if dict == nil {
+		return nil, ErrDictNotFound
+	}
+
+	if magic, ok := magics[compressType]; ok && !bytes.HasPrefix(in, magic) {
+		return nil, errs.Newf(errs.ErrServerDecompress,
+			"data is not compressed by compress type %d, magic bytes mismatch", compressType)
+	}
+
+	out, err := dc.DecompressDict(in, dict, MaxDecompressSize)
+	if err != nil {
+		return nil, decompressError(compressType, err)
+	}
+
+	return out, nil
+}
+
+// CompressDict implements DictCompressor.
+func (c *ZstdCompressor) CompressDict(in []byte, dict *Dictionary) ([]byte, error) {
+	return dict.zstdEncoder.EncodeAll(in, nil), nil
+}
+
+// DecompressDict implements DictCompressor.
+func (c *ZstdCompressor) DecompressDict(in []byte, dict *Dictionary, maxSize int64) ([]byte, error) {
+	zstdDecoder, ok := dict.zstdDecoderPool.Get().(*zstd.Decoder)
+
+	var err error
+	if ok {
+		err = zstdDecoder.Reset(bytes.NewReader(in))
+	} else {
+		zstdDecoder, err = zstd.NewReader(bytes.NewReader(in),
+			zstd.WithDecoderConcurrency(1), zstd.WithDecoderDictRaw(dict.ID, dict.Content))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer dict.zstdDecoderPool.Put(zstdDecoder)
+
+	return readAll(zstdDecoder, maxSize)
+}
+
+// TrainDictionary trains a dictionary of at most maxSize bytes from the sample payloads. The windows
+// of dictSegmentLength bytes found in more than one sample are extended to the maximal repeated
+// segments, the segments found in most samples are picked, and the windows already picked are
+// skipped, so that the dictionary is not filled with shifted copies of the same content. The more
+// frequent segments are put closer to the end of the dictionary, which is the nearest history when
+// compressing. maxSize <= 0 means DefaultDictSize.
+func TrainDictionary(samples [][]byte, maxSize int) []byte {
+	if maxSize <= 0 {
+		maxSize = DefaultDictSize
+	}
+
+	type segment struct {
+		content string
+		count   int
+	}
+
+	// number of samples that each window is found in.
+	counts := map[string]int{}
+	for _, sample := range samples {
+		seen := map[string]bool{}
+		for i := 0; i+dictSegmentLength <= len(sample); i++ {
+			s := string(sample[i : i+dictSegmentLength])
+			if !seen[s] {
+				seen[s] = true
+				counts[s]++
+			}
+		}
+	}
+
+	// extend the consecutive windows found in more than one sample (segment found in only one
+	// sample is useless) to the maximal repeated segment, whose count is the least of its windows.
+	repeats := map[string]int{}
+	for _, sample := range samples {
+		start, minCount := -1, 0
+		n := len(sample) - dictSegmentLength + 1 // number of windows
+		for i := 0; i <= n; i++ {
+			count := 0
+			if i < n {
+				count = counts[string(sample[i:i+dictSegmentLength])]
+			}
+
+			if count > 1 {
+				if start < 0 || count < minCount {
+					minCount = count
+				}
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+
+			if start >= 0 {
+				s := string(sample[start : i-1+dictSegmentLength])
+				if minCount > repeats[s] {
+					repeats[s] = minCount
+				}
+				start = -1
+			}
+		}
+	}
+
+	segments := make([]segment, 0, len(repeats))
+	for s, count := range repeats {
+		segments = append(segments, segment{s, count})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].count != segments[j].count {
+			return segments[i].count > segments[j].count
+		}
+		if len(segments[i].content) != len(segments[j].content) {
+			return len(segments[i].content) > len(segments[j].content)
+		}
+		return segments[i].content < segments[j].content
+	})
+
+	picked := make([]string, 0)
+	picks := map[string]bool{} // windows of the picked segments
+	size := 0
+	for _, s := range segments {
+		if maxSize-size < dictSegmentLength {
+			break
+		}
+
+		// only the runs of windows not picked yet are picked.
+		start := -1
+		n := len(s.content) - dictSegmentLength + 1 // number of windows
+		for i := 0; i <= n && size < maxSize; i++ {
+			if i < n && !picks[s.content[i:i+dictSegmentLength]] {
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+
+			if start < 0 {
+				continue
+			}
+
+			content := s.content[start : i-1+dictSegmentLength]
+			if len(content) > maxSize-size {
+				content = content[:maxSize-size]
+			}
+
+			for j := 0; j+dictSegmentLength <= len(content); j++ {
+				picks[content[j:j+dictSegmentLength]] = true
+			}
+
+			picked = append(picked, content)
+			size += len(content)
+			start = -1
+		}
+	}
+
+	// the most frequent segment is put at the end.
+	ret := make([]byte, 0, size)
+	for i := len(picked) - 1; i >= 0; i-- {
+		ret = append(ret, picked[i]...)
+	}
+
+	return ret
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/horm-database/common/consts"
+	"github.com/horm-database/common/errs"
+)
+
+// dictSamples returns small similar json payloads, such as requests of the same table.
+func dictSamples(n, offset int) [][]byte {
+	samples := make([][]byte, 0, n)
+	for i := offset; i < offset+n; i++ {
+		samples = append(samples, []byte(fmt.Sprintf(
+			`{"name":"user_info","op":"find_all","where":{"user_id":%d,"status":%d},`+
+				`"column":["user_id","nickname","avatar","created_at"],"limit":%d}`, i, i%3, i%50+1)))
+	}
+	return samples
+}
+
+func TestTrainedDictionary(t *testing.T) {
+	const dictID = 101
+
+	dict := TrainDictionary(dictSamples(200, 0), 0)
+	if len(dict) == 0 || len(dict) > DefaultDictSize {
+		t.Fatalf("trained dictionary size = %d, want (0, %d]", len(dict), DefaultDictSize)
+	}
+
+	if err := RegisterDictionary(dictID, dict); err != nil {
+		t.Fatal(err)
+	}
+
+	dictTotal, plainTotal := 0, 0
+	for _, payload := range dictSamples(50, 1000) { // payloads not in the samples.
+		compressed, err := CompressWithDict(consts.CompressTypeZstd, dictID, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := DecompressWithDict(consts.CompressTypeZstd, dictID, compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(out, payload) {
+			t.Fatalf("decompressed = %s, want %s", out, payload)
+		}
+
+		plain, err := CompressBy(consts.CompressTypeZstd, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dictTotal += len(compressed)
+		plainTotal += len(plain)
+	}
+
+	if dictTotal >= plainTotal {
+		t.Fatalf("compressed size with dictionary = %d, want less than %d without dictionary",
+			dictTotal, plainTotal)
+	}
+}
+
+func TestDecompressWithUnknownDict(t *testing.T) {
+	const dictID, otherDictID, unknownDictID = 102, 103, 104
+
+	samples := dictSamples(100, 0)
+	if err := RegisterDictionary(dictID, TrainDictionary(samples, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RegisterDictionary(otherDictID, []byte(`{"other":"dictionary","of":"another table"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	compressed, err := CompressWithDict(consts.CompressTypeZstd, dictID, samples[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = CompressWithDict(consts.CompressTypeZstd, unknownDictID, samples[0]); !errors.Is(err, ErrDictNotFound) {
+		t.Fatalf("CompressWithDict error = %v, want %v", err, ErrDictNotFound)
+	}
+
+	tests := []struct {
+		name   string
+		dictID uint32
+		err    error
+	}{
+		{"dictionary not registered", unknownDictID, ErrDictNotFound},
+		{"dictionary id mismatch", otherDictID, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := DecompressWithDict(consts.CompressTypeZstd, tt.dictID, compressed)
+			if err == nil {
+				t.Fatalf("DecompressWithDict = %s, want error", out)
+			}
+
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("DecompressWithDict error = %v, want %v", err, tt.err)
+			}
+
+			if code := errs.Code(err); code != errs.ErrServerDecompress {
+				t.Fatalf("error code = %d, want %d", code, errs.ErrServerDecompress)
+			}
+		})
+	}
+}
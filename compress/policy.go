@@ -0,0 +1,130 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/horm-database/common/consts"
+	"github.com/horm-database/common/metrics"
+)
+
+const (
+	DefaultCompressThreshold = 1024       // payloads smaller than it are not compressed by default.
+	MetricsCompressPolicy    = "compress" // record name of compress policy metrics.
+)
+
+// LevelRule is the compression level used for payloads not smaller than MinSize.
+type LevelRule struct {
+	MinSize int `yaml:"min_size" json:"min_size"`
+	Level   int `yaml:"level" json:"level"`
+}
+
+// Policy decides whether and how to compress a payload. It compresses only payloads not smaller
+// than Threshold, chooses the compression level by payload size and gives up the compressed data
+// if it does not shrink. The compression ratio and cpu time are reported through metrics.Report
+// with dimension policy name and compress type, so that the policy can be tuned per service.
+type Policy struct {
+	Name      string      `yaml:"name" json:"name"`           // name of policy, such as service name.
+	Threshold int         `yaml:"threshold" json:"threshold"` // min payload size to compress.
+	Levels    []LevelRule `yaml:"levels" json:"levels"`       // level rules, the default level is used if none matched.
+}
+
+// NewPolicy create a compress policy with default threshold, and levels that payload
+// smaller than 64KB is compressed by default level, larger one by best speed.
+func NewPolicy(name string) *Policy {
+	return &Policy{
+		Name:      name,
+		Threshold: DefaultCompressThreshold,
+		Levels: []LevelRule{
+			{MinSize: 0, Level: DefaultLevel},
+			{MinSize: 64 * 1024, Level: BestSpeedLevel},
+		},
+	}
+}
+
+// Level returns the compression level of the payload size, and whether a rule is matched.
+func (p *Policy) Level(size int) (int, bool) {
+	level, matched, maxMinSize := 0, false, -1
+	for _, rule := range p.Levels {
+		if size >= rule.MinSize && rule.MinSize > maxMinSize {
+			level, matched, maxMinSize = rule.Level, true, rule.MinSize
+		}
+	}
+	return level, matched
+}
+
+// Compress compresses in by the compressor of compress type according to the policy, returns the
+// output and the compress type actually used, which is CompressTypeNone if in is not compressed.
+func (p *Policy) Compress(compressType uint32, in []byte) ([]byte, uint32, error) {
+	if compressType == consts.CompressTypeNone {
+		return in, consts.CompressTypeNone, nil
+	}
+
+	if len(in) < p.Threshold || len(in) == 0 {
+		p.report(compressType, "skip_small", 1)
+		return in, consts.CompressTypeNone, nil
+	}
+
+	c := GetCompressor(compressType)
+	if c == nil {
+		return nil, compressType, fmt.Errorf("compressor of compress type %d not registered", compressType)
+	}
+
+	start := time.Now()
+
+	var out []byte
+	var err error
+
+	level, ok := p.Level(len(in))
+	if lc, isLevel := c.(LevelCompressor); ok && isLevel {
+		out, err = lc.CompressLevel(in, level)
+	} else {
+		out, err = c.Compress(in)
+	}
+
+	if err != nil {
+		return nil, compressType, err
+	}
+
+	cost := time.Since(start)
+
+	if len(out) >= len(in) {
+		p.report(compressType, "skip_not_shrink", 1,
+			metrics.NewMetrics("cpu_time_us", float64(cost.Microseconds()), metrics.PolicyAVG))
+		return in, consts.CompressTypeNone, nil
+	}
+
+	p.report(compressType, "compressed", 1,
+		metrics.NewMetrics("ratio", float64(len(out))/float64(len(in)), metrics.PolicyAVG),
+		metrics.NewMetrics("cpu_time_us", float64(cost.Microseconds()), metrics.PolicyAVG),
+		metrics.NewMetrics("origin_size", float64(len(in)), metrics.PolicySUM),
+		metrics.NewMetrics("compressed_size", float64(len(out)), metrics.PolicySUM))
+
+	return out, compressType, nil
+}
+
+// report reports the metrics of compress policy.
+func (p *Policy) report(compressType uint32, counter string, value float64, ms ...*metrics.Metrics) {
+	dimensions := []*metrics.Dimension{
+		{Name: "policy", Value: p.Name},
+		{Name: "compress_type", Value: strconv.FormatUint(uint64(compressType), 10)},
+	}
+
+	ms = append(ms, metrics.NewMetrics(counter, value, metrics.PolicySUM))
+
+	_ = metrics.Report(metrics.NewMultiDimensionMetricsX(MetricsCompressPolicy, dimensions, ms))
+}
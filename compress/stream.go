@@ -0,0 +1,205 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/horm-database/common/consts"
+	"github.com/horm-database/common/json"
+	jsoniter "github.com/json-iterator/go"
+)
+
+const streamReaderSize = 4 * 1024 // buffer size of streaming json decoder.
+
+var ErrJsonArrayEncoderClosed = errors.New("json array encoder is closed")
+
+// JsonArrayEncoder encodes a json array into gzip stream element by element, so that the whole
+// json document of huge result set is never built in memory. It is not goroutine-safe.
+type JsonArrayEncoder struct {
+	gzipWriter *gzip.Writer
+	stream     *jsoniter.Stream
+	count      int
+	closed     bool
+}
+
+// NewJsonArrayEncoder create a json array encoder, which writes the compressed data into w.
+// Close must be called after all elements have been encoded.
+func NewJsonArrayEncoder(w io.Writer) *JsonArrayEncoder {
+	gzipWriter, ok := gzipWriterPool.Get().(*gzip.Writer)
+	if !ok {
+		gzipWriter = gzip.NewWriter(w)
+	} else {
+		gzipWriter.Reset(w)
+	}
+
+	return &JsonArrayEncoder{
+		gzipWriter: gzipWriter,
+		stream:     json.Api.BorrowStream(gzipWriter),
+	}
+}
+
+// Encode encodes an element of array.
+func (e *JsonArrayEncoder) Encode(v interface{}) error {
+	if e.closed {
+		return ErrJsonArrayEncoderClosed
+	}
+
+	if e.count == 0 {
+		e.stream.WriteArrayStart()
+	} else {
+		e.stream.WriteMore()
+	}
+
+	e.stream.WriteVal(v)
+	e.count++
+
+	if e.stream.Error != nil {
+		return e.stream.Error
+	}
+
+	return e.stream.Flush()
+}
+
+// Close ends the json array and flushes the gzip stream, then puts the gzip writer back into pool.
+func (e *JsonArrayEncoder) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	if e.count == 0 {
+		e.stream.WriteEmptyArray()
+	} else {
+		e.stream.WriteArrayEnd()
+	}
+
+	err := e.stream.Flush()
+	json.Api.ReturnStream(e.stream)
+
+	if closeErr := e.gzipWriter.Close(); err == nil {
+		err = closeErr
+	}
+	gzipWriterPool.Put(e.gzipWriter)
+
+	return err
+}
+
+// JsonMarshalAndCompressTo encodes the result set into w element by element, then compression,
+// it is the streaming variant of JsonMarshalAndCompress.
+func JsonMarshalAndCompressTo(w io.Writer, data []map[string]interface{}) error {
+	enc := NewJsonArrayEncoder(w)
+
+	for _, v := range data {
+		if err := enc.Encode(v); err != nil {
+			_ = enc.Close()
+			return err
+		}
+	}
+
+	return enc.Close()
+}
+
+// DecompressJsonUnmarshalEach decompression and decodes the json array from r element by element,
+// fn is called for each element, and decoding stops once fn returns error. r is decoded as it is
+// if it is not gzip compressed, which is detected by magic bytes. The decompressed stream is
+// limited by MaxDecompressSize, ErrDecompressTooLarge is returned if it exceeds, and the errors
+// of decompressing have code errs.ErrServerDecompress.
+func DecompressJsonUnmarshalEach(r io.Reader, fn func(elem map[string]interface{}) error) error {
+	br := bufio.NewReaderSize(r, streamReaderSize)
+
+	var reader io.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, magics[consts.CompressTypeGzip]) {
+		gzipReader, ok := gzipReaderPool.Get().(*gzip.Reader)
+
+		var err error
+		if ok {
+			err = gzipReader.Reset(br)
+		} else {
+			gzipReader, err = gzip.NewReader(br)
+		}
+
+		if err != nil {
+			return decompressError(consts.CompressTypeGzip, err)
+		}
+
+		defer gzipReaderPool.Put(gzipReader)
+		reader = &limitGzipReader{r: gzipReader, remaining: MaxDecompressSize}
+	}
+
+	iter := jsoniter.Parse(json.Api, reader, streamReaderSize)
+
+	var fnErr error
+	iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+		var elem map[string]interface{}
+		iter.ReadVal(&elem)
+		if iter.Error != nil {
+			return false
+		}
+
+		fnErr = fn(elem)
+		return fnErr == nil
+	})
+
+	if fnErr != nil {
+		return fnErr
+	}
+
+	if lr, ok := reader.(*limitGzipReader); ok && lr.err != nil {
+		return lr.err
+	}
+
+	if iter.Error != nil && iter.Error != io.EOF {
+		return iter.Error
+	}
+
+	return nil
+}
+
+// limitGzipReader limits the size of decompressed gzip stream, and keeps the error of decompressing,
+// which is mapped to errs.ErrServerDecompress.
+type limitGzipReader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+// Read implements io.Reader.
+func (l *limitGzipReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+
+	if int64(len(p)) > l.remaining+1 { // read one more byte to find out whether it exceeds.
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		l.err = ErrDecompressTooLarge
+		return 0, l.err
+	}
+
+	if err != nil && err != io.EOF {
+		l.err = decompressError(consts.CompressTypeGzip, err)
+		return n, l.err
+	}
+
+	return n, err
+}
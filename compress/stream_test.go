@@ -0,0 +1,89 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/horm-database/common/errs"
+	"github.com/horm-database/common/json"
+)
+
+func TestDecompressJsonUnmarshalEach(t *testing.T) {
+	data := make([]map[string]interface{}, 0, 100)
+	for i := 0; i < 100; i++ {
+		data = append(data, map[string]interface{}{"id": float64(i), "name": "horm"})
+	}
+
+	compressed := bytes.Buffer{}
+	if err := JsonMarshalAndCompressTo(&compressed, data); err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := json.Api.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bomb := bytes.Buffer{}
+	w := gzip.NewWriter(&bomb)
+	_, _ = w.Write([]byte("["))
+	for i := 0; i < 1024; i++ {
+		_, _ = w.Write(bytes.Repeat([]byte(`{"a":"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"},`), 16))
+	}
+	_, _ = w.Write([]byte(`{}]`))
+	_ = w.Close()
+
+	badHeader := append([]byte{}, compressed.Bytes()...)
+	badHeader[2] = 0 // compression method other than deflate.
+
+	tests := []struct {
+		name    string
+		in      []byte
+		maxSize int64
+		count   int
+		code    int
+		err     error
+	}{
+		{"gzip", compressed.Bytes(), MaxDecompressSize, 100, 0, nil},
+		{"not compressed", plain, MaxDecompressSize, 100, 0, nil},
+		{"exactly max size", compressed.Bytes(), int64(len(plain)), 100, 0, nil},
+		{"decompression bomb", bomb.Bytes(), 64 * 1024, -1, errs.ErrServerDecompress, ErrDecompressTooLarge},
+		{"bad gzip header", badHeader, MaxDecompressSize, 0, errs.ErrServerDecompress, nil},
+		{"truncated gzip", compressed.Bytes()[:compressed.Len()/2], MaxDecompressSize, -1, errs.ErrServerDecompress, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(maxSize int64) { MaxDecompressSize = maxSize }(MaxDecompressSize)
+			MaxDecompressSize = tt.maxSize
+
+			count := 0
+			err := DecompressJsonUnmarshalEach(bytes.NewReader(tt.in), func(elem map[string]interface{}) error {
+				count++
+				return nil
+			})
+
+			if errs.Code(err) != tt.code || tt.err != nil && err != tt.err {
+				t.Fatalf("DecompressJsonUnmarshalEach error = %v, want code %d", err, tt.code)
+			}
+
+			if tt.count >= 0 && count != tt.count {
+				t.Fatalf("%d elements decoded, want %d", count, tt.count)
+			}
+		})
+	}
+}
@@ -36,6 +36,14 @@ const (
 	Compression   = 1
 )
 
+const ( // compress type of body, the value of Compress in request/response header
+	CompressTypeNone   = NoCompression
+	CompressTypeGzip   = Compression
+	CompressTypeZstd   = 2
+	CompressTypeSnappy = 3
+	CompressTypeLZ4    = 4
+)
+
 const ( //request type
 	RequestTypeRPC  = 0
 	RequestTypeHTTP = 1
@@ -35,4 +35,8 @@ var (
 	ConnectionPoolIdleTimeout      = Counter("ConnectionPoolIdleTimeout")
 	ConnectionPoolLifetimeExceed   = Counter("ConnectionPoolLifetimeExceed")
 	ConnectionPoolOverLimit        = Counter("ConnectionPoolOverLimit")
+
+	ReplayDetectorPass   = Counter("ReplayDetectorPass")   // request passed the replay detection
+	ReplayDetectorHit    = Counter("ReplayDetectorHit")    // replayed request detected
+	ReplayDetectorReject = Counter("ReplayDetectorReject") // request rejected for timestamp out of window or detector full
 )
@@ -44,21 +44,22 @@ type RequestHeader struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Version     uint32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`                            // 客户端版本
-	RequestType uint32 `protobuf:"varint,2,opt,name=request_type,json=requestType,proto3" json:"request_type,omitempty"` // 请求类型 0-rpc 请求 1-http 请求 2-web 请求
-	QueryMode   uint32 `protobuf:"varint,3,opt,name=query_mode,json=queryMode,proto3" json:"query_mode,omitempty"`       // 查询模式 0-单执行单元（默认）1-多执行单元并行（不含嵌套子查询） 2-复合查询（包含嵌套子查询）
-	RequestId   uint64 `protobuf:"varint,4,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`       // 请求唯一id
-	TraceId     string `protobuf:"bytes,5,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`              // trace_id
-	Timestamp   uint64 `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`                        // 请求时间戳（精确到毫秒）
-	Timeout     uint32 `protobuf:"varint,7,opt,name=timeout,proto3" json:"timeout,omitempty"`                            // 请求超时时间，单位ms
-	Caller      string `protobuf:"bytes,8,opt,name=caller,proto3" json:"caller,omitempty"`                               // 主调服务的名称 app.server.service
-	Callee      string `protobuf:"bytes,9,opt,name=callee,proto3" json:"callee,omitempty"`                               // 被调服务的路由名称 app.server.service/func
-	Appid       uint64 `protobuf:"varint,10,opt,name=appid,proto3" json:"appid,omitempty"`                               // appid
-	Compress    uint32 `protobuf:"varint,11,opt,name=compress,proto3" json:"compress,omitempty"`                         // 是否压缩 1-压缩；0-不压缩(默认)
-	Ip          string `protobuf:"bytes,12,opt,name=ip,proto3" json:"ip,omitempty"`                                      // ip地址
-	AuthRand    uint32 `protobuf:"varint,13,opt,name=auth_rand,json=authRand,proto3" json:"auth_rand,omitempty"`         // 随机生成 0-99999999 的数字，相同 timestamp 不允许出现同样的 ip、auth_rand。为了避免碰撞，0-99999999，单机理论最大支持 1000 亿/秒的并发。
-	Sign        string `protobuf:"bytes,14,opt,name=sign,proto3" json:"sign,omitempty"`                                  // sign 签名，为 md5(appid+secret+version+request_type+query_mode+request_id+trace_id+timestamp+timeout+caller+compress+auth_rand)
-	Bak         string `protobuf:"bytes,15,opt,name=bak,proto3" json:"bak,omitempty"`                                    // 备用
+	Version      uint32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`                                // 客户端版本
+	RequestType  uint32 `protobuf:"varint,2,opt,name=request_type,json=requestType,proto3" json:"request_type,omitempty"`     // 请求类型 0-rpc 请求 1-http 请求 2-web 请求
+	QueryMode    uint32 `protobuf:"varint,3,opt,name=query_mode,json=queryMode,proto3" json:"query_mode,omitempty"`           // 查询模式 0-单执行单元（默认）1-多执行单元并行（不含嵌套子查询） 2-复合查询（包含嵌套子查询）
+	RequestId    uint64 `protobuf:"varint,4,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`           // 请求唯一id
+	TraceId      string `protobuf:"bytes,5,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`                  // trace_id
+	Timestamp    uint64 `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`                            // 请求时间戳（精确到毫秒）
+	Timeout      uint32 `protobuf:"varint,7,opt,name=timeout,proto3" json:"timeout,omitempty"`                                // 请求超时时间，单位ms
+	Caller       string `protobuf:"bytes,8,opt,name=caller,proto3" json:"caller,omitempty"`                                   // 主调服务的名称 app.server.service
+	Callee       string `protobuf:"bytes,9,opt,name=callee,proto3" json:"callee,omitempty"`                                   // 被调服务的路由名称 app.server.service/func
+	Appid        uint64 `protobuf:"varint,10,opt,name=appid,proto3" json:"appid,omitempty"`                                   // appid
+	Compress     uint32 `protobuf:"varint,11,opt,name=compress,proto3" json:"compress,omitempty"`                             // 压缩类型 0-不压缩(默认)；1-gzip；2-zstd；3-snappy；4-lz4
+	Ip           string `protobuf:"bytes,12,opt,name=ip,proto3" json:"ip,omitempty"`                                          // ip地址
+	AuthRand     uint32 `protobuf:"varint,13,opt,name=auth_rand,json=authRand,proto3" json:"auth_rand,omitempty"`             // 随机生成 0-99999999 的数字，相同 timestamp 不允许出现同样的 ip、auth_rand。为了避免碰撞，0-99999999，单机理论最大支持 1000 亿/秒的并发。
+	Sign         string `protobuf:"bytes,14,opt,name=sign,proto3" json:"sign,omitempty"`                                      // sign 签名，为 md5(appid+secret+version+request_type+query_mode+request_id+trace_id+timestamp+timeout+caller+compress+ip+auth_rand)
+	Bak          string `protobuf:"bytes,15,opt,name=bak,proto3" json:"bak,omitempty"`                                        // 透传元数据，是一个 json 串，内容为：map[string]string
+	CompressDict uint32 `protobuf:"varint,16,opt,name=compress_dict,json=compressDict,proto3" json:"compress_dict,omitempty"` // 压缩字典 id，0-不使用字典(默认)
 }
 
 func (x *RequestHeader) Reset() {
@@ -198,20 +199,28 @@ func (x *RequestHeader) GetBak() string {
 	return ""
 }
 
+func (x *RequestHeader) GetCompressDict() uint32 {
+	if x != nil {
+		return x.CompressDict
+	}
+	return 0
+}
+
 // ResponseHeader 响应头
 type ResponseHeader struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Version   uint32            `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`                                                                                                        // 客户端版本
-	QueryMode uint32            `protobuf:"varint,2,opt,name=query_mode,json=queryMode,proto3" json:"query_mode,omitempty"`                                                                                   // 查询模式 0-单执行单元（默认）1-多执行单元并行（不含嵌套子查询） 2-复合查询（包含嵌套子查询）
-	RequestId uint64            `protobuf:"varint,3,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`                                                                                   // 请求唯一id
-	Compress  uint32            `protobuf:"varint,4,opt,name=compress,proto3" json:"compress,omitempty"`                                                                                                      // 返回结果是否压缩 1-压缩；0-不压缩(默认)
-	Err       *Error            `protobuf:"bytes,5,opt,name=err,proto3" json:"err,omitempty"`                                                                                                                 // 返回错误
-	IsNil     bool              `protobuf:"varint,6,opt,name=is_nil,json=isNil,proto3" json:"is_nil,omitempty"`                                                                                               // 返回是否为空（针对单执行单元）
-	RspErrs   map[string]*Error `protobuf:"bytes,7,rep,name=rsp_errs,json=rspErrs,proto3" json:"rsp_errs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`  // 错误返回（针对多执行单元并发）
-	RspNils   map[string]bool   `protobuf:"bytes,8,rep,name=rsp_nils,json=rspNils,proto3" json:"rsp_nils,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"` // 是否为空返回（针对多执行单元并发）
+	Version      uint32            `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`                                                                                                        // 客户端版本
+	QueryMode    uint32            `protobuf:"varint,2,opt,name=query_mode,json=queryMode,proto3" json:"query_mode,omitempty"`                                                                                   // 查询模式 0-单执行单元（默认）1-多执行单元并行（不含嵌套子查询） 2-复合查询（包含嵌套子查询）
+	RequestId    uint64            `protobuf:"varint,3,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`                                                                                   // 请求唯一id
+	Compress     uint32            `protobuf:"varint,4,opt,name=compress,proto3" json:"compress,omitempty"`                                                                                                      // 返回结果压缩类型，同请求压缩类型
+	Err          *Error            `protobuf:"bytes,5,opt,name=err,proto3" json:"err,omitempty"`                                                                                                                 // 返回错误
+	IsNil        bool              `protobuf:"varint,6,opt,name=is_nil,json=isNil,proto3" json:"is_nil,omitempty"`                                                                                               // 返回是否为空（针对单执行单元）
+	RspErrs      map[string]*Error `protobuf:"bytes,7,rep,name=rsp_errs,json=rspErrs,proto3" json:"rsp_errs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`  // 错误返回（针对多执行单元并发）
+	RspNils      map[string]bool   `protobuf:"bytes,8,rep,name=rsp_nils,json=rspNils,proto3" json:"rsp_nils,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"` // 是否为空返回（针对多执行单元并发）
+	CompressDict uint32            `protobuf:"varint,9,opt,name=compress_dict,json=compressDict,proto3" json:"compress_dict,omitempty"`                                                                          // 返回结果压缩字典 id，同请求压缩字典 id
 }
 
 func (x *ResponseHeader) Reset() {
@@ -302,6 +311,13 @@ func (x *ResponseHeader) GetRspNils() map[string]bool {
 	return nil
 }
 
+func (x *ResponseHeader) GetCompressDict() uint32 {
+	if x != nil {
+		return x.CompressDict
+	}
+	return 0
+}
+
 type Error struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -376,7 +392,7 @@ func (x *Error) GetSql() string {
 var File_header_proto protoreflect.FileDescriptor
 
 var file_header_proto_rawDesc = []byte{
-	0x0a, 0x0c, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x92,
+	0x0a, 0x0c, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xb7,
 	0x03, 0x0a, 0x0d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72,
 	0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28,
 	0x0d, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x65,
@@ -402,39 +418,44 @@ var file_header_proto_rawDesc = []byte{
 	0x01, 0x28, 0x0d, 0x52, 0x08, 0x61, 0x75, 0x74, 0x68, 0x52, 0x61, 0x6e, 0x64, 0x12, 0x12, 0x0a,
 	0x04, 0x73, 0x69, 0x67, 0x6e, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x73, 0x69, 0x67,
 	0x6e, 0x12, 0x10, 0x0a, 0x03, 0x62, 0x61, 0x6b, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
-	0x62, 0x61, 0x6b, 0x22, 0xa7, 0x03, 0x0a, 0x0e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
-	0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
-	0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
-	0x12, 0x1d, 0x0a, 0x0a, 0x71, 0x75, 0x65, 0x72, 0x79, 0x5f, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x71, 0x75, 0x65, 0x72, 0x79, 0x4d, 0x6f, 0x64, 0x65, 0x12,
-	0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20,
-	0x01, 0x28, 0x04, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x12, 0x1a,
-	0x0a, 0x08, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d,
-	0x52, 0x08, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x12, 0x18, 0x0a, 0x03, 0x65, 0x72,
-	0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x06, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52,
-	0x03, 0x65, 0x72, 0x72, 0x12, 0x15, 0x0a, 0x06, 0x69, 0x73, 0x5f, 0x6e, 0x69, 0x6c, 0x18, 0x06,
-	0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x69, 0x73, 0x4e, 0x69, 0x6c, 0x12, 0x37, 0x0a, 0x08, 0x72,
-	0x73, 0x70, 0x5f, 0x65, 0x72, 0x72, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e,
-	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x2e, 0x52,
-	0x73, 0x70, 0x45, 0x72, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x72, 0x73, 0x70,
-	0x45, 0x72, 0x72, 0x73, 0x12, 0x37, 0x0a, 0x08, 0x72, 0x73, 0x70, 0x5f, 0x6e, 0x69, 0x6c, 0x73,
-	0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
-	0x65, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x2e, 0x52, 0x73, 0x70, 0x4e, 0x69, 0x6c, 0x73, 0x45,
-	0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x72, 0x73, 0x70, 0x4e, 0x69, 0x6c, 0x73, 0x1a, 0x42, 0x0a,
-	0x0c, 0x52, 0x73, 0x70, 0x45, 0x72, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a,
-	0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
-	0x1c, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x06,
-	0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
-	0x01, 0x1a, 0x3a, 0x0a, 0x0c, 0x52, 0x73, 0x70, 0x4e, 0x69, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72,
-	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
-	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
-	0x28, 0x08, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x53, 0x0a,
-	0x05, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f,
-	0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x10,
-	0x0a, 0x03, 0x6d, 0x73, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x73, 0x67,
-	0x12, 0x10, 0x0a, 0x03, 0x73, 0x71, 0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x73,
-	0x71, 0x6c, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x62, 0x61, 0x6b, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x5f,
+	0x64, 0x69, 0x63, 0x74, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x63, 0x6f, 0x6d, 0x70,
+	0x72, 0x65, 0x73, 0x73, 0x44, 0x69, 0x63, 0x74, 0x22, 0xcc, 0x03, 0x0a, 0x0e, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x76,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x71, 0x75, 0x65, 0x72, 0x79, 0x5f, 0x6d,
+	0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x71, 0x75, 0x65, 0x72, 0x79,
+	0x4d, 0x6f, 0x64, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f,
+	0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x12,
+	0x18, 0x0a, 0x03, 0x65, 0x72, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x06, 0x2e, 0x45,
+	0x72, 0x72, 0x6f, 0x72, 0x52, 0x03, 0x65, 0x72, 0x72, 0x12, 0x15, 0x0a, 0x06, 0x69, 0x73, 0x5f,
+	0x6e, 0x69, 0x6c, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x69, 0x73, 0x4e, 0x69, 0x6c,
+	0x12, 0x37, 0x0a, 0x08, 0x72, 0x73, 0x70, 0x5f, 0x65, 0x72, 0x72, 0x73, 0x18, 0x07, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x65, 0x61,
+	0x64, 0x65, 0x72, 0x2e, 0x52, 0x73, 0x70, 0x45, 0x72, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x07, 0x72, 0x73, 0x70, 0x45, 0x72, 0x72, 0x73, 0x12, 0x37, 0x0a, 0x08, 0x72, 0x73, 0x70,
+	0x5f, 0x6e, 0x69, 0x6c, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x2e, 0x52, 0x73, 0x70,
+	0x4e, 0x69, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x72, 0x73, 0x70, 0x4e, 0x69,
+	0x6c, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x5f, 0x64,
+	0x69, 0x63, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x63, 0x6f, 0x6d, 0x70, 0x72,
+	0x65, 0x73, 0x73, 0x44, 0x69, 0x63, 0x74, 0x1a, 0x42, 0x0a, 0x0c, 0x52, 0x73, 0x70, 0x45, 0x72,
+	0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x1c, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x06, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x3a, 0x0a, 0x0c, 0x52,
+	0x73, 0x70, 0x4e, 0x69, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x53, 0x0a, 0x05, 0x45, 0x72, 0x72, 0x6f, 0x72,
+	0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04,
+	0x74, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x73, 0x67, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x73, 0x67, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x71,
+	0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x73, 0x71, 0x6c, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
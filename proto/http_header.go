@@ -14,21 +14,152 @@
 
 package proto
 
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/horm-database/common/consts"
+	"github.com/horm-database/common/errs"
+	"github.com/horm-database/common/json"
+)
+
 const (
-	HeaderVersion      = "head-version"    // 客户端版本
-	HeaderQueryMode    = "head-query-mode" // 查询模式 0-单执行单元（默认）1-多执行单元并行（不含嵌套子查询） 2-复合查询（包含嵌套子查询）
-	HeaderRequestID    = "head-request-id" // 请求唯一id
-	HeaderTraceID      = "head-trace-id"   // trace-id
-	HeaderTimestamp    = "head-timestamp"  // 请求时间戳（精确到毫秒）
-	HeaderTimeout      = "head-timeout"    // 请求超时时间，单位ms
-	HeaderCaller       = "head-caller"     // 主调服务的名称 app.server.service
-	HeaderAppid        = "head-appid"      // appid
-	HeaderAuthRand     = "head-auth-rand"  // 随机生成 0-9999999 的数字，相同 timestamp 不允许出现同样的 ip、auth_rand。为了避免碰撞，0-9999999，单机理论最大支持 100 亿/秒的并发。
-	HeaderSign         = "head-sign"       // sign 签名，为 md5(appid+secret+version+request_type+query_mode+request_id+trace_id+timestamp+timeout+caller+compress+ip+auth_rand)
-	HeaderIsNil        = "head-is-nil"     // 返回是否为空（针对单执行单元）
-	HeaderErrorType    = "head-error-type" // 错误类型
-	HeaderErrorCode    = "head-error-code" // 错误码
-	HeaderErrorMessage = "head-error-msg"  // 错误消息
-	HeaderRspNils      = "head-rsp-nils"   // 是否为空返回，是一个 json 串，内容为：map[string]bool（针对多执行单元并发）
-	HeaderRspErrs      = "head-rsp-errs"   // 错误返回，是一个json 串，内容为：map[string]Error（针对多执行单元并发）
+	HeaderVersion      = "head-version"       // 客户端版本
+	HeaderQueryMode    = "head-query-mode"    // 查询模式 0-单执行单元（默认）1-多执行单元并行（不含嵌套子查询） 2-复合查询（包含嵌套子查询）
+	HeaderRequestID    = "head-request-id"    // 请求唯一id
+	HeaderTraceID      = "head-trace-id"      // trace-id
+	HeaderTimestamp    = "head-timestamp"     // 请求时间戳（精确到毫秒）
+	HeaderTimeout      = "head-timeout"       // 请求超时时间，单位ms
+	HeaderCaller       = "head-caller"        // 主调服务的名称 app.server.service
+	HeaderAppid        = "head-appid"         // appid
+	HeaderCompress     = "head-compress"      // 压缩类型 0-不压缩(默认)；1-gzip；2-zstd；3-snappy；4-lz4
+	HeaderCompressDict = "head-compress-dict" // 压缩字典 id，0-不使用字典(默认)
+	HeaderAuthRand     = "head-auth-rand"     // 随机生成 0-9999999 的数字，相同 timestamp 不允许出现同样的 ip、auth_rand。为了避免碰撞，0-9999999，单机理论最大支持 100 亿/秒的并发。
+	HeaderSign         = "head-sign"          // sign 签名，为 md5(appid+secret+version+request_type+query_mode+request_id+trace_id+timestamp+timeout+caller+compress+ip+auth_rand)
+	HeaderIsNil        = "head-is-nil"        // 返回是否为空（针对单执行单元）
+	HeaderErrorType    = "head-error-type"    // 错误类型
+	HeaderErrorCode    = "head-error-code"    // 错误码
+	HeaderErrorMessage = "head-error-msg"     // 错误消息
+	HeaderRspNils      = "head-rsp-nils"      // 是否为空返回，是一个 json 串，内容为：map[string]bool（针对多执行单元并发）
+	HeaderRspErrs      = "head-rsp-errs"      // 错误返回，是一个json 串，内容为：map[string]Error（针对多执行单元并发）
 )
+
+// SetHTTPResponseHeader populates head-* http headers from the response header,
+// RspNils and RspErrs are json encoded.
+func SetHTTPResponseHeader(header http.Header, rsp *ResponseHeader) error {
+	header.Set(HeaderVersion, strconv.FormatUint(uint64(rsp.GetVersion()), 10))
+	header.Set(HeaderQueryMode, strconv.FormatUint(uint64(rsp.GetQueryMode()), 10))
+	header.Set(HeaderRequestID, strconv.FormatUint(rsp.GetRequestId(), 10))
+	header.Set(HeaderCompress, strconv.FormatUint(uint64(rsp.GetCompress()), 10))
+	if rsp.GetCompressDict() != 0 {
+		header.Set(HeaderCompressDict, strconv.FormatUint(uint64(rsp.GetCompressDict()), 10))
+	}
+	header.Set(HeaderIsNil, strconv.FormatBool(rsp.GetIsNil()))
+
+	if e := rsp.GetErr(); e != nil {
+		header.Set(HeaderErrorType, strconv.Itoa(int(e.GetType())))
+		header.Set(HeaderErrorCode, strconv.Itoa(int(e.GetCode())))
+		header.Set(HeaderErrorMessage, e.GetMsg())
+	}
+
+	if len(rsp.GetRspNils()) > 0 {
+		rspNils, err := json.Api.MarshalToString(rsp.GetRspNils())
+		if err != nil {
+			return errs.Newf(errs.ErrServerEncode, "encode %s error: %v", HeaderRspNils, err)
+		}
+		header.Set(HeaderRspNils, rspNils)
+	}
+
+	if len(rsp.GetRspErrs()) > 0 {
+		rspErrs, err := json.Api.MarshalToString(rsp.GetRspErrs())
+		if err != nil {
+			return errs.Newf(errs.ErrServerEncode, "encode %s error: %v", HeaderRspErrs, err)
+		}
+		header.Set(HeaderRspErrs, rspErrs)
+	}
+
+	return nil
+}
+
+// ParseHTTPRequestHeader builds request header from the head-* http headers of request,
+// the ip is the host of remote address, the callee is the url path, and the metadata of
+// head-meta-* headers is json encoded in Bak. Empty numeric header
+// is treated as 0, while invalid one returns errs.ErrServerDecode error.
+func ParseHTTPRequestHeader(r *http.Request) (*RequestHeader, error) {
+	req := RequestHeader{
+		RequestType: consts.RequestTypeHTTP,
+		TraceId:     r.Header.Get(HeaderTraceID),
+		Caller:      r.Header.Get(HeaderCaller),
+		Callee:      strings.TrimPrefix(r.URL.Path, "/"),
+		Sign:        r.Header.Get(HeaderSign),
+	}
+
+	req.Ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+	if req.Ip == "" {
+		req.Ip = r.RemoteAddr
+	}
+
+	var err error
+	if req.Version, err = parseUint32Header(r.Header, HeaderVersion); err != nil {
+		return nil, err
+	}
+
+	if req.QueryMode, err = parseUint32Header(r.Header, HeaderQueryMode); err != nil {
+		return nil, err
+	}
+
+	if req.RequestId, err = parseUintHeader(r.Header, HeaderRequestID, 64); err != nil {
+		return nil, err
+	}
+
+	if req.Timestamp, err = parseUintHeader(r.Header, HeaderTimestamp, 64); err != nil {
+		return nil, err
+	}
+
+	if req.Timeout, err = parseUint32Header(r.Header, HeaderTimeout); err != nil {
+		return nil, err
+	}
+
+	if req.Appid, err = parseUintHeader(r.Header, HeaderAppid, 64); err != nil {
+		return nil, err
+	}
+
+	if req.Compress, err = parseUint32Header(r.Header, HeaderCompress); err != nil {
+		return nil, err
+	}
+
+	if req.AuthRand, err = parseUint32Header(r.Header, HeaderAuthRand); err != nil {
+		return nil, err
+	}
+
+	if req.CompressDict, err = parseUint32Header(r.Header, HeaderCompressDict); err != nil {
+		return nil, err
+	}
+
+	if err = req.SetMetaData(ParseHTTPMetaData(r.Header)); err != nil {
+		return nil, errs.Newf(errs.ErrServerDecode, "decode http metadata error: %v", err)
+	}
+
+	return &req, nil
+}
+
+func parseUintHeader(header http.Header, key string, bitSize int) (uint64, error) {
+	v := header.Get(key)
+	if v == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.ParseUint(v, 10, bitSize)
+	if err != nil {
+		return 0, errs.Newf(errs.ErrServerDecode, "invalid http header %s: %v", key, err)
+	}
+
+	return i, nil
+}
+
+func parseUint32Header(header http.Header, key string) (uint32, error) {
+	i, err := parseUintHeader(header, key, 32)
+	return uint32(i), err
+}
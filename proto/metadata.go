@@ -0,0 +1,87 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/horm-database/common/json"
+)
+
+// HeaderMetaPrefix is the prefix of http headers carrying metadata, such as head-meta-tenant.
+// http headers are case-insensitive, so the metadata key parsed from http headers is lowercase.
+const HeaderMetaPrefix = "head-meta-"
+
+// MetaData returns the transparent metadata of request, which is json encoded in Bak.
+func (x *RequestHeader) MetaData() (map[string]string, error) {
+	if x.GetBak() == "" {
+		return nil, nil
+	}
+
+	md := map[string]string{}
+	if err := json.Api.UnmarshalFromString(x.GetBak(), &md); err != nil {
+		return nil, err
+	}
+
+	return md, nil
+}
+
+// SetMetaData sets the transparent metadata of request, which is json encoded in Bak.
+func (x *RequestHeader) SetMetaData(md map[string]string) error {
+	if len(md) == 0 {
+		x.Bak = ""
+		return nil
+	}
+
+	bak, err := json.Api.MarshalToString(md)
+	if err != nil {
+		return err
+	}
+
+	x.Bak = bak
+	return nil
+}
+
+// SetHTTPMetaData populates head-meta-* http headers from the metadata, values are url escaped.
+func SetHTTPMetaData(header http.Header, md map[string]string) {
+	for k, v := range md {
+		header.Set(HeaderMetaPrefix+k, url.QueryEscape(v))
+	}
+}
+
+// ParseHTTPMetaData returns the metadata from head-meta-* http headers, keys are lowercase.
+func ParseHTTPMetaData(header http.Header) map[string]string {
+	var md map[string]string
+
+	for k, vs := range header {
+		if len(k) <= len(HeaderMetaPrefix) || len(vs) == 0 ||
+			!strings.EqualFold(k[:len(HeaderMetaPrefix)], HeaderMetaPrefix) {
+			continue
+		}
+
+		v, err := url.QueryUnescape(vs[0])
+		if err != nil {
+			v = vs[0]
+		}
+
+		if md == nil {
+			md = map[string]string{}
+		}
+		md[strings.ToLower(k[len(HeaderMetaPrefix):])] = v
+	}
+
+	return md
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+	"sync"
+	"time"
+
+	"github.com/horm-database/common/errs"
+	"github.com/horm-database/common/metrics"
+)
+
+const (
+	replayShardNum            = 64          // shard number of replay detector
+	replayBucketSpan          = time.Second // time span of each bucket
+	defaultReplayBucketMaxLen = 100000      // default max entries of each bucket
+)
+
+// ReplayDetector detects replayed requests, as described in auth_rand of RequestHeader,
+// the same timestamp must never repeat the same ip and auth_rand. Requests whose timestamp
+// is out of the time window are rejected, requests within it are remembered in a ring of
+// one-second buckets, the bucket is evicted automatically when it is reused by a new second.
+type ReplayDetector struct {
+	window       time.Duration
+	bucketMaxLen int
+	shards       [replayShardNum]replayShard
+	now          func() time.Time // local time, replaced in test.
+}
+
+type replayShard struct {
+	mu      sync.Mutex
+	buckets []replayBucket
+}
+
+type replayBucket struct {
+	second int64 // unix second of timestamp that the bucket belongs to
+	seen   map[replayKey]struct{}
+}
+
+type replayKey struct {
+	timestamp uint64
+	ip        string
+	authRand  uint32
+}
+
+// NewReplayDetector create a replay detector, window is the max clock skew allowed between
+// timestamp of request and local time, default SignTimeWindow. bucketMaxLen is the max
+// request number remembered by each shard per second, the requests beyond it are rejected.
+func NewReplayDetector(window time.Duration, bucketMaxLen int) *ReplayDetector {
+	if window <= 0 {
+		window = SignTimeWindow
+	}
+
+	if bucketMaxLen <= 0 {
+		bucketMaxLen = defaultReplayBucketMaxLen
+	}
+
+	// timestamps within window span at most 2*window+1 seconds, so that the bucket
+	// is never reused by two seconds both within window.
+	bucketNum := int(2*window/replayBucketSpan) + 2
+
+	d := &ReplayDetector{window: window, bucketMaxLen: bucketMaxLen, now: time.Now}
+	for i := range d.shards {
+		d.shards[i].buckets = make([]replayBucket, bucketNum)
+	}
+
+	return d
+}
+
+// Check returns nil if the request is not a replay, otherwise returns errs.ErrAuthFail error.
+func (d *ReplayDetector) Check(h *RequestHeader) error {
+	return d.CheckAt(h.GetTimestamp(), h.GetIp(), h.GetAuthRand())
+}
+
+// CheckAt is same as Check, with timestamp (in milliseconds), ip and auth_rand of the request.
+func (d *ReplayDetector) CheckAt(timestamp uint64, ip string, authRand uint32) error {
+	skew := d.now().Sub(time.UnixMilli(int64(timestamp)))
+	if skew > d.window || skew < -d.window {
+		metrics.ReplayDetectorReject.Incr()
+		return errs.Newf(errs.ErrAuthFail, "timestamp %d is out of replay window", timestamp)
+	}
+
+	key := replayKey{timestamp: timestamp, ip: ip, authRand: authRand}
+	second := int64(timestamp) / int64(replayBucketSpan/time.Millisecond)
+
+	shard := &d.shards[key.hash()%replayShardNum]
+	shard.mu.Lock()
+
+	bucket := &shard.buckets[second%int64(len(shard.buckets))]
+	if bucket.second != second || bucket.seen == nil {
+		bucket.second = second
+		bucket.seen = make(map[replayKey]struct{})
+	}
+
+	if _, ok := bucket.seen[key]; ok {
+		shard.mu.Unlock()
+		metrics.ReplayDetectorHit.Incr()
+		return errs.New(errs.ErrAuthFail, "replayed request")
+	}
+
+	if len(bucket.seen) >= d.bucketMaxLen {
+		shard.mu.Unlock()
+		metrics.ReplayDetectorReject.Incr()
+		return errs.New(errs.ErrServerOverload, "replay detector is full")
+	}
+
+	bucket.seen[key] = struct{}{}
+	shard.mu.Unlock()
+
+	metrics.ReplayDetectorPass.Incr()
+	return nil
+}
+
+// hash returns fnv-1a hash of the key.
+func (k *replayKey) hash() uint32 {
+	const prime = 16777619
+	h := uint32(2166136261)
+
+	for i := 0; i < 8; i++ {
+		h = (h ^ uint32(byte(k.timestamp>>(8*i)))) * prime
+	}
+
+	for i := 0; i < 4; i++ {
+		h = (h ^ uint32(byte(k.authRand>>(8*i)))) * prime
+	}
+
+	for i := 0; i < len(k.ip); i++ {
+		h = (h ^ uint32(k.ip[i])) * prime
+	}
+
+	return h
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/horm-database/common/errs"
+)
+
+func TestReplayDetectorCheckAt(t *testing.T) {
+	now := time.UnixMilli(1700000000500)
+	ts := uint64(now.UnixMilli())
+	window := 5 * time.Second
+	windowMS := uint64(window / time.Millisecond)
+
+	type request struct {
+		timestamp uint64
+		ip        string
+		authRand  uint32
+		code      int // expected error code
+	}
+
+	tests := []struct {
+		name     string
+		requests []request
+	}{
+		{"first request", []request{{ts, "10.0.0.1", 1, 0}}},
+		{"duplicate", []request{{ts, "10.0.0.1", 1, 0}, {ts, "10.0.0.1", 1, errs.ErrAuthFail}}},
+		{"different ip, auth_rand or timestamp", []request{
+			{ts, "10.0.0.1", 1, 0},
+			{ts, "10.0.0.2", 1, 0},
+			{ts, "10.0.0.1", 2, 0},
+			{ts + 1, "10.0.0.1", 1, 0},
+			{ts, "10.0.0.1", 1, errs.ErrAuthFail},
+		}},
+		{"just inside window in the past", []request{{ts - windowMS + 1, "10.0.0.1", 1, 0}}},
+		{"at window edge in the past", []request{{ts - windowMS, "10.0.0.1", 1, 0}}},
+		{"just outside window in the past", []request{{ts - windowMS - 1, "10.0.0.1", 1, errs.ErrAuthFail}}},
+		{"just inside window in the future", []request{{ts + windowMS - 1, "10.0.0.1", 1, 0}}},
+		{"at window edge in the future", []request{{ts + windowMS, "10.0.0.1", 1, 0}}},
+		{"just outside window in the future", []request{{ts + windowMS + 1, "10.0.0.1", 1, errs.ErrAuthFail}}},
+		{"duplicate at both window edges", []request{
+			{ts - windowMS, "10.0.0.1", 1, 0},
+			{ts + windowMS, "10.0.0.1", 1, 0},
+			{ts - windowMS, "10.0.0.1", 1, errs.ErrAuthFail},
+			{ts + windowMS, "10.0.0.1", 1, errs.ErrAuthFail},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewReplayDetector(window, 0)
+			d.now = func() time.Time { return now }
+
+			for i, r := range tt.requests {
+				if err := d.CheckAt(r.timestamp, r.ip, r.authRand); errs.Code(err) != r.code {
+					t.Fatalf("request %d: CheckAt error = %v, want code %d", i, err, r.code)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayDetectorBucketReuse(t *testing.T) {
+	now := time.UnixMilli(1700000000500)
+	d := NewReplayDetector(time.Second, 1)
+	d.now = func() time.Time { return now }
+
+	bucketNum := int64(len(d.shards[0].buckets))
+	ts := uint64(now.UnixMilli())
+
+	// find another auth_rand of the same shard, so that they share the bucket.
+	key := replayKey{timestamp: ts, ip: "10.0.0.1", authRand: 1}
+	other := key
+	for other.authRand++; other.hash()%replayShardNum != key.hash()%replayShardNum; other.authRand++ {
+	}
+
+	if err := d.CheckAt(ts, key.ip, key.authRand); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CheckAt(ts, other.ip, other.authRand); errs.Code(err) != errs.ErrServerOverload {
+		t.Fatalf("CheckAt error = %v, want overload when the bucket is full", err)
+	}
+
+	// a duplicate is still detected when the bucket is full.
+	if err := d.CheckAt(ts, key.ip, key.authRand); errs.Code(err) != errs.ErrAuthFail {
+		t.Fatalf("CheckAt error = %v, want replayed", err)
+	}
+
+	// the ring wraps, the bucket of the old second is reused by the new second.
+	now = now.Add(time.Duration(bucketNum) * time.Second)
+	wrapped := ts + uint64(bucketNum*1000)
+
+	other = replayKey{timestamp: wrapped, ip: "10.0.0.1"}
+	for other.authRand = 1; other.hash()%replayShardNum != key.hash()%replayShardNum; other.authRand++ {
+	}
+
+	if err := d.CheckAt(wrapped, other.ip, other.authRand); err != nil {
+		t.Fatalf("CheckAt error = %v, the reused bucket is not reset", err)
+	}
+
+	bucket := d.shards[key.hash()%replayShardNum].buckets[int64(wrapped/1000)%bucketNum]
+	if _, ok := bucket.seen[key]; ok || len(bucket.seen) != 1 || bucket.second != int64(wrapped/1000) {
+		t.Fatal("entries of the old second are left in the reused bucket")
+	}
+
+	// the old timestamp is out of window now.
+	if err := d.CheckAt(ts, key.ip, key.authRand); errs.Code(err) != errs.ErrAuthFail {
+		t.Fatalf("CheckAt error = %v, want out of window", err)
+	}
+}
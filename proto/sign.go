@@ -0,0 +1,127 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/horm-database/common/consts"
+	"github.com/horm-database/common/crypto"
+	"github.com/horm-database/common/errs"
+)
+
+// SignTimeWindow is the max clock skew allowed between the timestamp of request header and local time.
+var SignTimeWindow = 5 * time.Minute
+
+// SignRequestHeader returns the sign of request header, which is
+// md5(appid+secret+version+request_type+query_mode+request_id+trace_id+timestamp+timeout+caller+compress+ip+auth_rand)
+func SignRequestHeader(h *RequestHeader, secret string) string {
+	return sign(
+		strconv.FormatUint(h.GetAppid(), 10),
+		secret,
+		strconv.FormatUint(uint64(h.GetVersion()), 10),
+		strconv.FormatUint(uint64(h.GetRequestType()), 10),
+		strconv.FormatUint(uint64(h.GetQueryMode()), 10),
+		strconv.FormatUint(h.GetRequestId(), 10),
+		h.GetTraceId(),
+		strconv.FormatUint(h.GetTimestamp(), 10),
+		strconv.FormatUint(uint64(h.GetTimeout()), 10),
+		h.GetCaller(),
+		strconv.FormatUint(uint64(h.GetCompress()), 10),
+		h.GetIp(),
+		strconv.FormatUint(uint64(h.GetAuthRand()), 10),
+	)
+}
+
+// VerifyRequestHeader verifies the timestamp and sign of request header,
+// returns errs.ErrAuthFail error if the verification fails.
+func VerifyRequestHeader(h *RequestHeader, secret string) error {
+	if err := checkSignTimestamp(h.GetTimestamp()); err != nil {
+		return err
+	}
+
+	return checkSign(h.GetSign(), SignRequestHeader(h, secret))
+}
+
+// SignHTTPHeader returns the sign of head-* http headers, ip is the address of client.
+// The request_type of http request is always consts.RequestTypeHTTP, numeric headers
+// that are empty is treated as 0.
+func SignHTTPHeader(header http.Header, secret, ip string) string {
+	return sign(
+		numHeader(header, HeaderAppid),
+		secret,
+		numHeader(header, HeaderVersion),
+		strconv.Itoa(consts.RequestTypeHTTP),
+		numHeader(header, HeaderQueryMode),
+		numHeader(header, HeaderRequestID),
+		header.Get(HeaderTraceID),
+		numHeader(header, HeaderTimestamp),
+		numHeader(header, HeaderTimeout),
+		header.Get(HeaderCaller),
+		numHeader(header, HeaderCompress),
+		ip,
+		numHeader(header, HeaderAuthRand),
+	)
+}
+
+// VerifyHTTPHeader verifies the timestamp and sign of head-* http headers, ip is the address
+// of client, returns errs.ErrAuthFail error if the verification fails.
+func VerifyHTTPHeader(header http.Header, secret, ip string) error {
+	timestamp, err := strconv.ParseUint(numHeader(header, HeaderTimestamp), 10, 64)
+	if err != nil {
+		return errs.Newf(errs.ErrAuthFail, "invalid %s: %v", HeaderTimestamp, err)
+	}
+
+	if err = checkSignTimestamp(timestamp); err != nil {
+		return err
+	}
+
+	return checkSign(header.Get(HeaderSign), SignHTTPHeader(header, secret, ip))
+}
+
+func sign(fields ...string) string {
+	var builder strings.Builder
+	for _, field := range fields {
+		builder.WriteString(field)
+	}
+	return crypto.MD5Str(builder.String())
+}
+
+func checkSign(sign, expected string) error {
+	if subtle.ConstantTimeCompare([]byte(sign), []byte(expected)) != 1 {
+		return errs.New(errs.ErrAuthFail, "sign verify failed")
+	}
+	return nil
+}
+
+// checkSignTimestamp checks whether the timestamp (in milliseconds) is within SignTimeWindow.
+func checkSignTimestamp(timestamp uint64) error {
+	skew := time.Since(time.UnixMilli(int64(timestamp)))
+	if skew > SignTimeWindow || skew < -SignTimeWindow {
+		return errs.Newf(errs.ErrAuthFail, "timestamp %d is out of sign time window", timestamp)
+	}
+	return nil
+}
+
+func numHeader(header http.Header, key string) string {
+	v := header.Get(key)
+	if v == "" {
+		return "0"
+	}
+	return v
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/horm-database/common/json"
+)
+
+// Exporter exports the finished spans to external system, it's goroutine-safe.
+type Exporter interface {
+	Name() string
+	Export(span *Span) error
+}
+
+var (
+	exporters     = map[string]Exporter{}
+	lockExporters = sync.RWMutex{}
+)
+
+// RegisterExporter registers an exporter, the exporter of the same name is replaced.
+func RegisterExporter(e Exporter) {
+	lockExporters.Lock()
+	exporters[e.Name()] = e
+	lockExporters.Unlock()
+}
+
+// UnregisterExporter unregisters the exporter of name.
+func UnregisterExporter(name string) {
+	lockExporters.Lock()
+	delete(exporters, name)
+	lockExporters.Unlock()
+}
+
+// ExportErrorHandler handles the error of exporting, errors are ignored if nil. The exporter is
+// empty for ErrSpanDropped, which is reported when the span is dropped by the full export queue.
+var ExportErrorHandler func(exporter string, err error)
+
+// ExportQueueSize is the max number of finished spans waiting to be exported, it should be
+// set before the first span is finished.
+var ExportQueueSize = 4096
+
+var (
+	ErrSpanDropped = errors.New("trace export queue is full, span dropped")
+)
+
+// exportTask is the finished span to be exported, or the flush request if flushed is not nil.
+type exportTask struct {
+	span    *Span
+	flushed chan struct{}
+}
+
+var (
+	exportQueue chan exportTask
+	startExport sync.Once
+)
+
+// export puts the span into the export queue without blocking, the spans in queue are exported by
+// a background goroutine, so that the request is not slowed down by the exporters, such as writing
+// file. The span is dropped if the queue is full.
+func export(span *Span) {
+	startExport.Do(startExportWorker)
+
+	select {
+	case exportQueue <- exportTask{span: span}:
+	default:
+		if ExportErrorHandler != nil {
+			ExportErrorHandler("", ErrSpanDropped)
+		}
+	}
+}
+
+// Flush waits until the spans finished before it are exported, such as before the process exits.
+func Flush(ctx context.Context) error {
+	startExport.Do(startExportWorker)
+
+	flushed := make(chan struct{})
+	select {
+	case exportQueue <- exportTask{flushed: flushed}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func startExportWorker() {
+	exportQueue = make(chan exportTask, ExportQueueSize)
+
+	go func() {
+		for task := range exportQueue {
+			if task.flushed != nil {
+				close(task.flushed)
+				continue
+			}
+			exportSpan(task.span)
+		}
+	}()
+}
+
+// exportSpan exports the span by the registered exporters, which are copied out of the lock,
+// so that a slow exporter does not block RegisterExporter.
+func exportSpan(span *Span) {
+	lockExporters.RLock()
+	list := make([]Exporter, 0, len(exporters))
+	for _, e := range exporters {
+		list = append(list, e)
+	}
+	lockExporters.RUnlock()
+
+	for _, e := range list {
+		if err := e.Export(span); err != nil && ExportErrorHandler != nil {
+			ExportErrorHandler(e.Name(), err)
+		}
+	}
+}
+
+// spanRecord is the json record of span exported by FileExporter.
+type spanRecord struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	Start        int64                  `json:"start"`    // start time, unix microseconds.
+	Duration     int64                  `json:"duration"` // duration in microseconds.
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	ErrCode      int                    `json:"err_code,omitempty"`
+	ErrMsg       string                 `json:"err_msg,omitempty"`
+}
+
+// FileExporter exports spans into file as json lines, one span per line.
+type FileExporter struct {
+	name string
+	file *os.File
+	mu   sync.Mutex
+}
+
+// NewFileExporter create a json lines file exporter, spans are appended to the file of path.
+func NewFileExporter(name, path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open trace file %s error: %v", path, err)
+	}
+
+	return &FileExporter{name: name, file: file}, nil
+}
+
+// Name implements Exporter.
+func (e *FileExporter) Name() string {
+	return e.name
+}
+
+// Export implements Exporter.
+func (e *FileExporter) Export(span *Span) error {
+	span.mu.Lock()
+	rec := spanRecord{
+		TraceID:    span.TraceID,
+		SpanID:     FormatSpanID(span.SpanID),
+		Name:       span.Name,
+		Kind:       span.Kind.String(),
+		Start:      span.Start.UnixMicro(),
+		Duration:   span.End.Sub(span.Start).Microseconds(),
+		Attributes: span.Attributes,
+		ErrCode:    span.ErrCode,
+		ErrMsg:     span.ErrMsg,
+	}
+
+	if span.ParentSpanID != 0 {
+		rec.ParentSpanID = FormatSpanID(span.ParentSpanID)
+	}
+
+	line, err := json.Api.Marshal(&rec)
+	span.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	line = append(line, '\n')
+
+	e.mu.Lock()
+	_, err = e.file.Write(line)
+	e.mu.Unlock()
+
+	return err
+}
+
+// Close closes the file.
+func (e *FileExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
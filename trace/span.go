@@ -0,0 +1,303 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/horm-database/common/codec"
+	"github.com/horm-database/common/errs"
+)
+
+// SpanKind is the kind of span.
+type SpanKind int8
+
+const (
+	SpanKindInternal SpanKind = 0 // internal operation of the service.
+	SpanKindServer   SpanKind = 1 // server handles the request of upstream.
+	SpanKindClient   SpanKind = 2 // client calls downstream.
+)
+
+var spanKindDesc = map[SpanKind]string{
+	SpanKindInternal: "internal",
+	SpanKindServer:   "server",
+	SpanKindClient:   "client",
+}
+
+// String returns the description of span kind.
+func (k SpanKind) String() string {
+	return spanKindDesc[k]
+}
+
+var (
+	sampleRatio     = 1.0
+	lockSampleRatio = sync.RWMutex{}
+)
+
+// SetSampleRatio sets the sample ratio 0-1 of the new trace, the trace started by upstream
+// follows the sampled flag of upstream. All traces are sampled by default.
+func SetSampleRatio(ratio float64) {
+	lockSampleRatio.Lock()
+	sampleRatio = ratio
+	lockSampleRatio.Unlock()
+}
+
+func sample() bool {
+	lockSampleRatio.RLock()
+	ratio := sampleRatio
+	lockSampleRatio.RUnlock()
+	return ratio >= 1 || randFloat64() < ratio
+}
+
+// randFloat64 returns a random number in [0, 1) by crypto/rand, since the global source of math/rand
+// is not randomly seeded before go 1.20, which samples the same traces in every process.
+func randFloat64() float64 {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return float64(binary.BigEndian.Uint64(b[:])>>11) / (1 << 53)
+}
+
+// Span records an operation of trace, it is exported to the registered exporters when it ends.
+type Span struct {
+	TraceID      string
+	SpanID       uint64
+	ParentSpanID uint64
+	Name         string
+	Kind         SpanKind
+	Sampled      bool
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]interface{}
+	ErrCode      int
+	ErrMsg       string
+
+	mu    sync.Mutex
+	ended bool
+	msg   *codec.Msg // the msg created by StartSpan or StartClientSpan, recycled by Finish.
+}
+
+// SetAttribute sets the attribute of span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.Attributes == nil {
+		s.Attributes = map[string]interface{}{}
+	}
+	s.Attributes[key] = value
+	s.mu.Unlock()
+}
+
+// SetError records the error of span.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.ErrCode, s.ErrMsg = errs.Code(err), errs.Msg(err)
+	s.mu.Unlock()
+}
+
+// Finish ends the span and exports it asynchronously if the trace is sampled, only the first
+// call takes effect. The msg created by StartSpan or StartClientSpan is recycled, so the context
+// returned by them and its msg must not be used after Finish.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	msg := s.msg
+	s.msg = nil
+	s.mu.Unlock()
+
+	if s.Sampled {
+		export(s)
+	}
+
+	if msg != nil {
+		codec.RecycleMessage(msg)
+	}
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the span of context, nil if not exists.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// StartServerSpan starts the server span of the request, whose trace context is extracted from the
+// traceparent and tracestate of msg metadata, which are set by the client span of upstream. If upstream
+// is not traced, a new trace is started, the trace id of msg is kept if it is a valid W3C trace id.
+// The trace id, span id, parent span id, flags and state of msg are updated by the span.
+func StartServerSpan(ctx context.Context, name string) (context.Context, *Span) {
+	msg := codec.Message(ctx)
+
+	if sc, err := ParseTraceParent(msg.MetaValue(HeaderTraceParent)); err == nil {
+		traceState, _ := ParseTraceState(msg.MetaValue(HeaderTraceState))
+		setMsgTrace(msg, sc.TraceID, sc.SpanID, sc.TraceFlags, traceState)
+	} else {
+		traceID := msg.TraceID()
+		if !ValidTraceID(traceID) {
+			traceID = NewTraceID()
+		}
+
+		var flags uint8
+		if sample() {
+			flags = TraceFlagSampled
+		}
+		setMsgTrace(msg, traceID, 0, flags, "")
+	}
+
+	return startSpan(ctx, msg, name, SpanKindServer)
+}
+
+// StartSpan starts an internal span, which is the child of the current span of msg.
+// The msg of returned context is a copy, so that the trace of parent is not changed.
+// The copy is owned by the span and recycled by Finish.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	ctx, msg := childMessage(ctx, copyMessage)
+	ctx, span := startSpan(ctx, msg, name, SpanKindInternal)
+	span.msg = msg
+	return ctx, span
+}
+
+// StartClientSpan starts a client span for the call to downstream, which is the child of the current
+// span of msg. The msg of returned context is the client msg derived by codec.NewClientMessage with a
+// new span id, whose traceparent and tracestate are injected into its metadata, so that they are
+// propagated by the client codec. The client msg is owned by the span and recycled by Finish,
+// so the client call should be completed before Finish.
+func StartClientSpan(ctx context.Context, name string) (context.Context, *Span) {
+	ctx, msg := childMessage(ctx, codec.NewClientMessage)
+	ctx, span := startSpan(ctx, msg, name, SpanKindClient)
+	span.msg = msg
+	Inject(msg)
+	return ctx, span
+}
+
+func init() {
+	codec.ClientTraceInjector = Inject
+}
+
+// Inject injects the traceparent and tracestate of msg into its metadata.
+func Inject(msg *codec.Msg) {
+	if !ValidTraceID(msg.TraceID()) || msg.SpanID() == 0 {
+		return
+	}
+
+	sc := SpanContext{
+		TraceID:    msg.TraceID(),
+		SpanID:     msg.SpanID(),
+		TraceFlags: msg.TraceFlags(),
+		TraceState: msg.TraceState(),
+	}
+
+	msg.WithMetaValue(HeaderTraceParent, sc.TraceParent())
+	if sc.TraceState != "" {
+		msg.WithMetaValue(HeaderTraceState, sc.TraceState)
+	}
+}
+
+// InjectHTTP sets the traceparent and tracestate http headers by the trace of msg.
+func InjectHTTP(header http.Header, msg *codec.Msg) {
+	if !ValidTraceID(msg.TraceID()) || msg.SpanID() == 0 {
+		return
+	}
+
+	sc := SpanContext{TraceID: msg.TraceID(), SpanID: msg.SpanID(), TraceFlags: msg.TraceFlags()}
+	header.Set(HeaderTraceParent, sc.TraceParent())
+	if msg.TraceState() != "" {
+		header.Set(HeaderTraceState, msg.TraceState())
+	}
+}
+
+// ExtractHTTP extracts the traceparent and tracestate http headers into the metadata of msg,
+// which are used by StartServerSpan. Invalid traceparent is ignored.
+func ExtractHTTP(header http.Header, msg *codec.Msg) {
+	if _, err := ParseTraceParent(header.Get(HeaderTraceParent)); err != nil {
+		return
+	}
+
+	msg.WithMetaValue(HeaderTraceParent, header.Get(HeaderTraceParent))
+	if traceState, err := ParseTraceState(header.Get(HeaderTraceState)); err == nil && traceState != "" {
+		msg.WithMetaValue(HeaderTraceState, traceState)
+	}
+}
+
+// copyMessage returns the context with a copy of msg.
+func copyMessage(ctx context.Context) (context.Context, *codec.Msg) {
+	parent := codec.Message(ctx)
+	ctx, msg := codec.NewMessage(ctx)
+	codec.CopyMsg(msg, parent)
+	return ctx, msg
+}
+
+// childMessage returns the context with the msg created by newMessage from msg of ctx,
+// whose parent span id is the span id of msg of ctx.
+func childMessage(ctx context.Context,
+	newMessage func(context.Context) (context.Context, *codec.Msg)) (context.Context, *codec.Msg) {
+	parent := codec.Message(ctx)
+	ctx, msg := newMessage(ctx)
+
+	traceID, flags := parent.TraceID(), parent.TraceFlags()
+	if !ValidTraceID(traceID) {
+		traceID = NewTraceID()
+		if sample() {
+			flags = TraceFlagSampled
+		}
+	}
+
+	setMsgTrace(msg, traceID, parent.SpanID(), flags, parent.TraceState())
+	return ctx, msg
+}
+
+// setMsgTrace sets the trace of msg, the span id of msg is left to startSpan.
+func setMsgTrace(msg *codec.Msg, traceID string, parentSpanID uint64, flags uint8, traceState string) {
+	msg.WithTraceID(traceID)
+	msg.WithParentSpanID(parentSpanID)
+	msg.WithTraceFlags(flags)
+	msg.WithTraceState(traceState)
+}
+
+func startSpan(ctx context.Context, msg *codec.Msg, name string, kind SpanKind) (context.Context, *Span) {
+	msg.WithSpanID(NewSpanID())
+
+	span := &Span{
+		TraceID:      msg.TraceID(),
+		SpanID:       msg.SpanID(),
+		ParentSpanID: msg.ParentSpanID(),
+		Name:         name,
+		Kind:         kind,
+		Sampled:      msg.TraceFlags()&TraceFlagSampled != 0,
+		Start:        time.Now(),
+	}
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
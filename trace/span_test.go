@@ -0,0 +1,154 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/horm-database/common/codec"
+)
+
+// memExporter keeps the exported spans in memory.
+type memExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (e *memExporter) Name() string {
+	return "mem"
+}
+
+func (e *memExporter) Export(span *Span) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, span)
+	e.mu.Unlock()
+	return nil
+}
+
+func TestSpanLinks(t *testing.T) {
+	e := &memExporter{}
+	RegisterExporter(e)
+	defer UnregisterExporter(e.Name())
+
+	upstream := SpanContext{TraceID: NewTraceID(), SpanID: NewSpanID(), TraceFlags: TraceFlagSampled}
+
+	ctx, msg := codec.NewMessage(context.Background())
+	defer codec.RecycleMessage(msg)
+	msg.WithMetaValue(HeaderTraceParent, upstream.TraceParent())
+	msg.WithMetaValue(HeaderTraceState, "rojo=1")
+
+	ctx, server := StartServerSpan(ctx, "server")
+	if server.TraceID != upstream.TraceID || server.ParentSpanID != upstream.SpanID ||
+		server.SpanID == upstream.SpanID || !server.Sampled || SpanFromContext(ctx) != server {
+		t.Fatalf("server span %+v is not the child of upstream %+v", server, upstream)
+	}
+
+	ictx, internal := StartSpan(ctx, "internal")
+	if internal.TraceID != server.TraceID || internal.ParentSpanID != server.SpanID ||
+		codec.Message(ictx).SpanID() != internal.SpanID || msg.SpanID() != server.SpanID {
+		t.Fatal("internal span is not the child of server span")
+	}
+
+	_, child := StartSpan(ictx, "child of internal")
+	if child.ParentSpanID != internal.SpanID {
+		t.Fatal("span is not the child of internal span")
+	}
+
+	cctx, client := StartClientSpan(ctx, "client")
+	clientMsg := codec.Message(cctx)
+	if client.TraceID != server.TraceID || client.ParentSpanID != server.SpanID ||
+		clientMsg.SpanID() != client.SpanID || clientMsg.Parent() != msg {
+		t.Fatal("client span is not the child of server span")
+	}
+
+	// the trace context of client span rather than upstream is propagated to downstream.
+	sc, err := ParseTraceParent(clientMsg.MetaValue(HeaderTraceParent))
+	if err != nil || sc.TraceID != server.TraceID || sc.SpanID != client.SpanID || !sc.Sampled() ||
+		clientMsg.MetaValue(HeaderTraceState) != "rojo=1" {
+		t.Fatalf("traceparent %s of client msg is not the client span", clientMsg.MetaValue(HeaderTraceParent))
+	}
+
+	for _, span := range []*Span{child, internal, client, server} {
+		span.Finish()
+	}
+	server.Finish() // only the first call takes effect.
+
+	if err = Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.spans) != 4 || e.spans[3] != server || server.End.IsZero() {
+		t.Fatalf("%d spans exported, want 4", len(e.spans))
+	}
+}
+
+func TestNewClientMessageTrace(t *testing.T) {
+	ctx, msg := codec.NewMessage(context.Background())
+	defer codec.RecycleMessage(msg)
+
+	upstream := SpanContext{TraceID: NewTraceID(), SpanID: NewSpanID(), TraceFlags: TraceFlagSampled}
+	msg.WithMetaValue(HeaderTraceParent, upstream.TraceParent())
+
+	ctx, server := StartServerSpan(ctx, "server")
+	defer server.Finish()
+
+	_, clientMsg := codec.NewClientMessage(ctx)
+	defer codec.RecycleMessage(clientMsg)
+
+	sc, err := ParseTraceParent(clientMsg.MetaValue(HeaderTraceParent))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sc.TraceID != upstream.TraceID || sc.SpanID != clientMsg.SpanID() ||
+		sc.SpanID == server.SpanID || clientMsg.ParentSpanID() != server.SpanID {
+		t.Fatalf("traceparent %+v of client msg is not the child of server span", sc)
+	}
+}
+
+func TestHTTPPropagation(t *testing.T) {
+	sc := SpanContext{TraceID: NewTraceID(), SpanID: NewSpanID(), TraceFlags: TraceFlagSampled}
+
+	header := http.Header{}
+	header.Set(HeaderTraceParent, sc.TraceParent())
+	header.Set(HeaderTraceState, "rojo=1, congo=2")
+
+	ctx, msg := codec.NewMessage(context.Background())
+	defer codec.RecycleMessage(msg)
+
+	ExtractHTTP(header, msg)
+	_, server := StartServerSpan(ctx, "server")
+	defer server.Finish()
+
+	out := http.Header{}
+	InjectHTTP(out, msg)
+
+	parsed, err := ParseTraceParent(out.Get(HeaderTraceParent))
+	if err != nil || parsed.TraceID != sc.TraceID || parsed.SpanID != server.SpanID ||
+		out.Get(HeaderTraceState) != "rojo=1,congo=2" {
+		t.Fatalf("http headers %v are not propagated from %v", out, header)
+	}
+
+	header.Set(HeaderTraceParent, "invalid")
+	msg2 := &codec.Msg{}
+	ExtractHTTP(header, msg2)
+	if msg2.MetaValue(HeaderTraceParent) != "" {
+		t.Fatal("invalid traceparent is extracted")
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trace provides W3C trace context propagation and span records built on the trace id
+// and span id of codec.Msg, finished spans are exported to the registered exporters.
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	HeaderTraceParent = "traceparent" // W3C trace context header, also the metadata key of rpc request.
+	HeaderTraceState  = "tracestate"  // W3C trace state header, also the metadata key of rpc request.
+
+	TraceFlagSampled = 0x01 // sampled flag of trace flags.
+
+	traceParentVersion = "00"
+	traceParentLen     = 55 // length of version 00 traceparent.
+	maxTraceStateLen   = 512
+	maxTraceStateItems = 32
+)
+
+var (
+	ErrTraceParent = errors.New("invalid traceparent")
+	ErrTraceState  = errors.New("invalid tracestate")
+)
+
+// SpanContext is the trace context propagated across process boundaries.
+type SpanContext struct {
+	TraceID    string // 32 lowercase hex characters.
+	SpanID     uint64 // span id of the caller, which is the parent span of callee.
+	TraceFlags uint8
+	TraceState string
+}
+
+// Sampled returns whether the trace is sampled.
+func (sc SpanContext) Sampled() bool {
+	return sc.TraceFlags&TraceFlagSampled != 0
+}
+
+// TraceParent returns the W3C traceparent of span context.
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("%s-%s-%016x-%02x", traceParentVersion, sc.TraceID, sc.SpanID, sc.TraceFlags)
+}
+
+// ParseTraceParent parses W3C traceparent, such as 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+// The higher version is parsed by the format of version 00, the following fields are ignored.
+func ParseTraceParent(traceParent string) (SpanContext, error) {
+	sc := SpanContext{}
+
+	traceParent = strings.TrimSpace(traceParent)
+	if len(traceParent) < traceParentLen {
+		return sc, ErrTraceParent
+	}
+
+	version := traceParent[:2]
+	if !isLowerHex(version) || version == "ff" ||
+		version == traceParentVersion && len(traceParent) != traceParentLen ||
+		len(traceParent) > traceParentLen && traceParent[traceParentLen] != '-' {
+		return sc, ErrTraceParent
+	}
+
+	if traceParent[2] != '-' || traceParent[35] != '-' || traceParent[52] != '-' {
+		return sc, ErrTraceParent
+	}
+
+	sc.TraceID = traceParent[3:35]
+	if !ValidTraceID(sc.TraceID) {
+		return sc, ErrTraceParent
+	}
+
+	spanID := traceParent[36:52]
+	if !isLowerHex(spanID) {
+		return sc, ErrTraceParent
+	}
+
+	sc.SpanID, _ = strconv.ParseUint(spanID, 16, 64)
+	if sc.SpanID == 0 {
+		return sc, ErrTraceParent
+	}
+
+	flags := traceParent[53:55]
+	if !isLowerHex(flags) {
+		return sc, ErrTraceParent
+	}
+
+	f, _ := strconv.ParseUint(flags, 16, 8)
+	sc.TraceFlags = uint8(f)
+
+	return sc, nil
+}
+
+// ParseTraceState validates W3C tracestate, returns it without optional white spaces.
+// The invalid tracestate should be discarded as a whole.
+func ParseTraceState(traceState string) (string, error) {
+	if traceState == "" {
+		return "", nil
+	}
+
+	if len(traceState) > maxTraceStateLen {
+		return "", ErrTraceState
+	}
+
+	items := strings.Split(traceState, ",")
+	members := make([]string, 0, len(items))
+	keys := make(map[string]bool, len(items))
+
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		i := strings.IndexByte(item, '=')
+		if i <= 0 || i == len(item)-1 {
+			return "", ErrTraceState
+		}
+
+		key, value := item[:i], item[i+1:]
+		if !validTraceStateKey(key) || !validTraceStateValue(value) || keys[key] {
+			return "", ErrTraceState
+		}
+
+		keys[key] = true
+		members = append(members, item)
+	}
+
+	if len(members) > maxTraceStateItems {
+		return "", ErrTraceState
+	}
+
+	return strings.Join(members, ","), nil
+}
+
+// NewTraceID generates a random W3C trace id by crypto/rand, which is unique across processes.
+func NewTraceID() string {
+	var b [16]byte
+	for {
+		_, _ = rand.Read(b[:])
+		if id := hex.EncodeToString(b[:]); id != invalidTraceID {
+			return id
+		}
+	}
+}
+
+// NewSpanID generates a random non-zero span id by crypto/rand.
+func NewSpanID() uint64 {
+	var b [8]byte
+	for {
+		_, _ = rand.Read(b[:])
+		if id := binary.BigEndian.Uint64(b[:]); id != 0 {
+			return id
+		}
+	}
+}
+
+// FormatSpanID returns the 16 lowercase hex characters of span id.
+func FormatSpanID(id uint64) string {
+	return fmt.Sprintf("%016x", id)
+}
+
+const invalidTraceID = "00000000000000000000000000000000"
+
+// ValidTraceID returns whether the trace id is a valid W3C trace id.
+func ValidTraceID(traceID string) bool {
+	return len(traceID) == 32 && isLowerHex(traceID) && traceID != invalidTraceID
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// validTraceStateKey validates key of tracestate, which is simple-key or tenant-id@system-id.
+func validTraceStateKey(key string) bool {
+	if len(key) > 256 {
+		return false
+	}
+
+	tenant, system := key, ""
+	if i := strings.IndexByte(key, '@'); i >= 0 {
+		tenant, system = key[:i], key[i+1:]
+		if tenant == "" || system == "" || len(tenant) > 241 || len(system) > 14 {
+			return false
+		}
+	}
+
+	for _, part := range []string{tenant, system} {
+		for i := 0; i < len(part); i++ {
+			c := part[i]
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+				c == '_' || c == '-' || c == '*' || c == '/') {
+				return false
+			}
+		}
+	}
+
+	c := tenant[0]
+	return c >= 'a' && c <= 'z' || system != "" && c >= '0' && c <= '9'
+}
+
+// validTraceStateValue validates value of tracestate, which is printable ascii except ',' and '='.
+func validTraceStateValue(value string) bool {
+	if len(value) > 256 || value[len(value)-1] == ' ' {
+		return false
+	}
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < 0x20 || c > 0x7e || c == ',' || c == '=' {
+			return false
+		}
+	}
+	return true
+}
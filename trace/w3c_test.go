@@ -0,0 +1,139 @@
+// Copyright (c) 2024 The horm-database Authors. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceParent string
+		want        SpanContext
+		err         error
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: 0x00f067aa0ba902b7, TraceFlags: 1}, nil},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: 0x00f067aa0ba902b7}, nil},
+		{"white spaces", " 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01 ",
+			SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: 0x00f067aa0ba902b7, TraceFlags: 1}, nil},
+		{"higher version with more fields", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: 0x00f067aa0ba902b7, TraceFlags: 1}, nil},
+		{"empty", "", SpanContext{}, ErrTraceParent},
+		{"too short", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0", SpanContext{}, ErrTraceParent},
+		{"version 00 too long", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			SpanContext{}, ErrTraceParent},
+		{"invalid version ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", SpanContext{}, ErrTraceParent},
+		{"higher version without dash", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x",
+			SpanContext{}, ErrTraceParent},
+		{"upper case trace id", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", SpanContext{}, ErrTraceParent},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", SpanContext{}, ErrTraceParent},
+		{"zero span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", SpanContext{}, ErrTraceParent},
+		{"invalid span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01", SpanContext{}, ErrTraceParent},
+		{"invalid flags", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g", SpanContext{}, ErrTraceParent},
+		{"bad separator", "00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", SpanContext{}, ErrTraceParent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceParent(tt.traceParent)
+			if err != tt.err {
+				t.Fatalf("ParseTraceParent error = %v, want %v", err, tt.err)
+			}
+
+			if err == nil && sc != tt.want {
+				t.Fatalf("ParseTraceParent = %+v, want %+v", sc, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraceParentRoundTrip(t *testing.T) {
+	for _, flags := range []uint8{0, TraceFlagSampled} {
+		sc := SpanContext{TraceID: NewTraceID(), SpanID: NewSpanID(), TraceFlags: flags}
+
+		parsed, err := ParseTraceParent(sc.TraceParent())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed != sc || parsed.Sampled() != (flags == TraceFlagSampled) {
+			t.Fatalf("parsed span context %+v mismatch with %+v", parsed, sc)
+		}
+	}
+
+	if id := FormatSpanID(0x00f067aa0ba902b7); id != "00f067aa0ba902b7" {
+		t.Fatalf("FormatSpanID = %s", id)
+	}
+}
+
+func TestParseTraceState(t *testing.T) {
+	long := make([]byte, maxTraceStateLen+1)
+	for i := range long {
+		long[i] = 'a'
+	}
+
+	many := "k0=v"
+	for i := 1; i <= maxTraceStateItems; i++ {
+		many += ",k" + string(rune('a'+i%26)) + string(rune('a'+i/26)) + "=v"
+	}
+
+	tests := []struct {
+		name       string
+		traceState string
+		want       string
+		err        error
+	}{
+		{"empty", "", "", nil},
+		{"single", "congo=t61rcWkgMzE", "congo=t61rcWkgMzE", nil},
+		{"multiple with white spaces", "rojo=00f067aa0ba902b7 , congo=t61rcWkgMzE",
+			"rojo=00f067aa0ba902b7,congo=t61rcWkgMzE", nil},
+		{"empty members", "rojo=1,,congo=2,", "rojo=1,congo=2", nil},
+		{"multi-tenant key", "tenant@system=value", "tenant@system=value", nil},
+		{"missing value", "rojo=", "", ErrTraceState},
+		{"missing key", "=value", "", ErrTraceState},
+		{"no equal sign", "rojo", "", ErrTraceState},
+		{"upper case key", "Rojo=1", "", ErrTraceState},
+		{"duplicate key", "rojo=1,rojo=2", "", ErrTraceState},
+		{"invalid value", "rojo=a,b=c=d", "", ErrTraceState},
+		{"too long", string(long), "", ErrTraceState},
+		{"too many members", many, "", ErrTraceState},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			traceState, err := ParseTraceState(tt.traceState)
+			if err != tt.err {
+				t.Fatalf("ParseTraceState error = %v, want %v", err, tt.err)
+			}
+
+			if traceState != tt.want {
+				t.Fatalf("ParseTraceState = %q, want %q", traceState, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewID(t *testing.T) {
+	traceIDs, spanIDs := map[string]bool{}, map[uint64]bool{}
+	for i := 0; i < 1000; i++ {
+		traceID, spanID := NewTraceID(), NewSpanID()
+		if !ValidTraceID(traceID) || spanID == 0 || traceIDs[traceID] || spanIDs[spanID] {
+			t.Fatalf("invalid or duplicate id %s %d", traceID, spanID)
+		}
+		traceIDs[traceID], spanIDs[spanID] = true, true
+	}
+}